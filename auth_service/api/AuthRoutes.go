@@ -13,11 +13,23 @@ func AuthRoutes(router *gin.RouterGroup) *gin.RouterGroup {
 
 	router.POST("/login_account", handlers.Login)
 
+	router.POST("/refresh_token", handlers.RefreshToken)
+
 	router.GET("/auth_verifications", handlers.Checklogin)
 
 	router.GET("/verify_token_claims", handlers.VerifyTokenClaims)
 
 	router.GET("/get_keypair", handlers.GetKeyPair)
 
+	router.POST("/ws_ticket", handlers.IssueWsTicket)
+
+	router.PUT("/update_locale", handlers.UpdateLocale)
+
+	router.GET("/sessions", handlers.ListSessions)
+
+	router.DELETE("/logout_session", handlers.LogoutSession)
+
+	router.DELETE("/logout_all", handlers.LogoutAll)
+
 	return router
 }
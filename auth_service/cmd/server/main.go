@@ -3,6 +3,7 @@ package main
 import (
 	"auth_service/api"
 	"auth_service/internal/database"
+	"auth_service/internal/mailer"
 	"log"
 	"os"
 
@@ -13,6 +14,7 @@ import (
 func init() {
 	database.LoadInitializers()
 	database.ConnectToDb()
+	mailer.Init()
 }
 
 func main() {
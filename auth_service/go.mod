@@ -5,6 +5,7 @@ go 1.24.5
 require (
 	github.com/gin-contrib/cors v1.7.6
 	github.com/rs/cors v1.11.1
+	golang.org/x/text v0.27.0
 )
 
 require (
@@ -41,7 +42,6 @@ require (
 	golang.org/x/net v0.41.0 // indirect
 	golang.org/x/sync v0.16.0 // indirect
 	golang.org/x/sys v0.34.0 // indirect
-	golang.org/x/text v0.27.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	gorm.io/driver/postgres v1.6.0 // indirect
@@ -6,6 +6,7 @@ import (
 	"auth_service/internal/utils"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -72,6 +73,16 @@ func Checklogin(c *gin.Context) {
 		return
 	}
 
+	if _, err := loadActiveSession(database.DB, claims, time.Now()); err != nil {
+		c.JSON(401, utils.Response{
+			Code:    401,
+			Success: false,
+			Message: "Unauthorized",
+			Error:   err.Error(),
+		})
+		return
+	}
+
 	tx := database.DB.Begin()
 
 	var user models.User
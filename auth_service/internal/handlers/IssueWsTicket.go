@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"auth_service/internal/database"
+	"auth_service/internal/models"
+	"auth_service/internal/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const wsTicketTTL = 30 * time.Second
+
+// issueWsTicketRequest binds the ticket to one conversation, or to the
+// user-scoped socket when ConversationID is left out.
+type issueWsTicketRequest struct {
+	ConversationID uint `json:"conversation_id"`
+}
+
+type issueWsTicketResponse struct {
+	utils.Response
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IssueWsTicket exchanges the caller's JWT for a short-lived, single-use
+// ticket that can be placed in the chat WebSocket URL.
+func IssueWsTicket(c *gin.Context) {
+	var request issueWsTicketRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid request format",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	session, claims, ok := authenticate(c)
+	if !ok {
+		return
+	}
+
+	var profile models.Profile
+	if err := database.DB.Where("email = ?", claims["email"]).First(&profile).Error; err != nil {
+		c.JSON(404, utils.Response{
+			Code:    404,
+			Success: false,
+			Message: "Profile not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ticket, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to generate ticket",
+		})
+		return
+	}
+
+	now := time.Now()
+	wsTicket := models.WsTicket{
+		TicketHash:     utils.HashToken(ticket),
+		ProfileID:      profile.ID,
+		ConversationID: request.ConversationID,
+		ExpiresAt:      now.Add(wsTicketTTL),
+		SessionID:      session.SessionID,
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Unscoped().Where("expires_at < ?", now).Delete(&models.WsTicket{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to clean up expired tickets",
+		})
+		return
+	}
+
+	if err := tx.Create(&wsTicket).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to store ticket",
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to commit transaction",
+		})
+		return
+	}
+
+	c.JSON(200, issueWsTicketResponse{
+		Response: utils.Response{
+			Code:    200,
+			Success: true,
+			Message: "Ticket issued",
+		},
+		Ticket:    ticket,
+		ExpiresAt: wsTicket.ExpiresAt,
+	})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"auth_service/internal/database"
+	"auth_service/internal/models"
+	"auth_service/internal/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type sessionView struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+type listSessionsResponse struct {
+	utils.Response
+	Data []sessionView `json:"data"`
+}
+
+// ListSessions returns the caller's live sessions, most recently used first.
+func ListSessions(c *gin.Context) {
+	current, _, ok := authenticate(c)
+	if !ok {
+		return
+	}
+
+	var sessions []models.Session
+	if err := database.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", current.UserID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to load sessions",
+		})
+		return
+	}
+
+	views := make([]sessionView, 0, len(sessions))
+	for _, session := range sessions {
+		views = append(views, sessionView{
+			ID:         session.SessionID,
+			Device:     session.Device,
+			IP:         session.IP,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			Current:    session.SessionID == current.SessionID,
+		})
+	}
+
+	c.JSON(200, listSessionsResponse{
+		Response: utils.Response{
+			Code:    200,
+			Success: true,
+			Message: "Sessions retrieved successfully",
+		},
+		Data: views,
+	})
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"auth_service/internal/database"
+	"auth_service/internal/mailer"
 	"auth_service/internal/models"
 	"auth_service/internal/utils"
 	"errors"
@@ -17,12 +18,16 @@ import (
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	// Device names the session in the session list; the user agent is
+	// used when it is left out.
+	Device string `json:"device"`
 }
 
 type LoginResponse struct {
 	utils.Response
-	Token      string `json:"token"`
-	PrivateKey string `json:"private_key"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	PrivateKey   string `json:"private_key"`
 }
 
 func Login(c *gin.Context) {
@@ -162,6 +167,7 @@ func Login(c *gin.Context) {
 				Salt:     fmt.Sprintf("%v", salt),
 				Username: username,
 				Verified: true,
+				Locale:   mailer.MatchLocale(c.GetHeader("Accept-Language")),
 			}
 			if err := tx.Create(&user).Error; err != nil {
 				tx.Rollback()
@@ -182,7 +188,7 @@ func Login(c *gin.Context) {
 				PublicKey:    publicKey,
 				ProfileImage: ProfilePicture,
 				AboutMe:      "",
-				Status:       models.StatusActive,
+				Status:       models.StatusInactive,
 				LastSeen:     time.Now(),
 			}
 
@@ -244,7 +250,32 @@ func Login(c *gin.Context) {
 		}
 	}
 
-	tokenString, err := utils.GenerateToken(user.Email, user.ID, 24*time.Hour)
+	now := time.Now()
+	unfamiliarDevice, err := rememberDevice(tx, user.ID, c.Request.UserAgent(), now)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to record device",
+		})
+		return
+	}
+
+	session, err := startSession(tx, c, user.ID, request.Device, now)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to start session",
+		})
+		return
+	}
+
+	tokenString, err := utils.GenerateToken(user.Email, user.ID, session.SessionID, accessTokenTTL)
 	if err != nil {
 		tx.Rollback()
 		c.JSON(500, utils.Response{
@@ -256,6 +287,28 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if err := deleteExpiredRefreshTokens(tx, now); err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to clean up expired refresh tokens",
+		})
+		return
+	}
+	refreshToken, err := issueRefreshToken(tx, user.ID, session.SessionID, now)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to issue refresh token",
+		})
+		return
+	}
+
 	user.LastLogin = time.Now()
 	if err := tx.Save(&user).Error; err != nil {
 		tx.Rollback()
@@ -290,6 +343,15 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if unfamiliarDevice {
+		notify(user.Email, user.Locale, mailer.TemplateNewDevice, mailer.NewDeviceData{
+			AppName:   appName(),
+			Time:      formatMailTime(now),
+			UserAgent: c.Request.UserAgent(),
+			IP:        c.ClientIP(),
+		})
+	}
+
 	response := utils.Response{
 		Code:    200,
 		Success: true,
@@ -298,8 +360,9 @@ func Login(c *gin.Context) {
 	}
 
 	c.JSON(200, LoginResponse{
-		Response:   response,
-		Token:      tokenString,
-		PrivateKey: privateKey,
+		Response:     response,
+		Token:        tokenString,
+		RefreshToken: refreshToken,
+		PrivateKey:   privateKey,
 	})
 }
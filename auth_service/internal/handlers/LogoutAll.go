@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"auth_service/internal/database"
+	"auth_service/internal/mailer"
+	"auth_service/internal/models"
+	"auth_service/internal/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LogoutAll ends every session of the caller, the current one included, and
+// lets them know by email.
+func LogoutAll(c *gin.Context) {
+	current, _, ok := authenticate(c)
+	if !ok {
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var user models.User
+	if err := tx.Where("id = ?", current.UserID).First(&user).Error; err != nil {
+		tx.Rollback()
+		c.JSON(404, utils.Response{
+			Code:    404,
+			Success: false,
+			Message: "User not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	var sessionIDs []string
+	if err := tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", user.ID).
+		Pluck("session_id", &sessionIDs).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to load sessions",
+		})
+		return
+	}
+
+	now := time.Now()
+	if err := revokeSessions(tx, sessionIDs, now); err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to revoke sessions",
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to commit transaction",
+		})
+		return
+	}
+
+	notify(user.Email, user.Locale, mailer.TemplateAccountChanged, mailer.AccountChangedData{
+		AppName: appName(),
+		Change:  "sessions",
+		Time:    formatMailTime(now),
+	})
+
+	c.JSON(200, utils.Response{
+		Code:    200,
+		Success: true,
+		Message: "Logged out of all sessions",
+	})
+}
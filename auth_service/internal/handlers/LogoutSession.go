@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"auth_service/internal/database"
+	"auth_service/internal/models"
+	"auth_service/internal/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type logoutSessionRequest struct {
+	// SessionID defaults to the session of the request.
+	SessionID string `form:"session_id"`
+}
+
+// LogoutSession ends one of the caller's sessions, by default the current
+// one. Its access tokens stop working at once and its refresh token is
+// revoked.
+func LogoutSession(c *gin.Context) {
+	var request logoutSessionRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid request format",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	current, _, ok := authenticate(c)
+	if !ok {
+		return
+	}
+	if request.SessionID == "" {
+		request.SessionID = current.SessionID
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var session models.Session
+	if err := tx.Where("session_id = ? AND user_id = ? AND revoked_at IS NULL", request.SessionID, current.UserID).
+		First(&session).Error; err != nil {
+		tx.Rollback()
+		c.JSON(404, utils.Response{
+			Code:    404,
+			Success: false,
+			Message: "Session not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := revokeSessions(tx, []string{session.SessionID}, time.Now()); err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to revoke session",
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to commit transaction",
+		})
+		return
+	}
+
+	c.JSON(200, utils.Response{
+		Code:    200,
+		Success: true,
+		Message: "Logged out",
+	})
+}
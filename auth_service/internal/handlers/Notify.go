@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"auth_service/internal/mailer"
+	"auth_service/internal/models"
+	"auth_service/internal/utils"
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const notifyTimeout = time.Minute
+
+// appName is how account emails name the service, from SENDER_NAME.
+func appName() string {
+	if name := os.Getenv("SENDER_NAME"); name != "" {
+		return name
+	}
+	return "ZTA chat"
+}
+
+func formatMailTime(t time.Time) string {
+	return t.UTC().Format("Mon, 02 Jan 2006 15:04 MST")
+}
+
+// sendMail renders a template in locale and sends it to one address.
+func sendMail(ctx context.Context, to, locale string, name mailer.Template, data any) error {
+	message, err := mailer.Render(name, locale, data)
+	if err != nil {
+		return err
+	}
+	message.To = []string{to}
+	return mailer.Default.Send(ctx, message)
+}
+
+// notify sends a notice in the background. The request that caused it
+// neither waits for nor fails on the delivery.
+func notify(to, locale string, name mailer.Template, data any) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := sendMail(ctx, to, locale, name, data); err != nil {
+			log.Printf("failed to send %s email: %v", name, err)
+		}
+	}()
+}
+
+// rememberDevice records that userID signed in with userAgent. unfamiliar
+// reports a device seen for the first time by a user who already had others,
+// which is when a new-device notice is due.
+func rememberDevice(tx *gorm.DB, userID uint, userAgent string, now time.Time) (unfamiliar bool, err error) {
+	hash := utils.HashToken(userAgent)
+	result := tx.Model(&models.KnownDevice{}).
+		Where("user_id = ? AND device_hash = ?", userID, hash).
+		Update("last_seen_at", now)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return false, result.Error
+	}
+
+	var known int64
+	if err := tx.Model(&models.KnownDevice{}).Where("user_id = ?", userID).Count(&known).Error; err != nil {
+		return false, err
+	}
+	result = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.KnownDevice{
+		UserID:     userID,
+		DeviceHash: hash,
+		LastSeenAt: now,
+	})
+	return result.RowsAffected > 0 && known > 0, result.Error
+}
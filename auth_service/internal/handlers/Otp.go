@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"auth_service/internal/database"
+	"auth_service/internal/models"
+	"auth_service/internal/utils"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	otpTTL            = 10 * time.Minute
+	otpResendCooldown = time.Minute
+	maxOtpAttempts    = 5
+
+	// otpLockout is how long an email is locked after its first
+	// maxOtpAttempts wrong codes in a row. Every further run of
+	// maxOtpAttempts locks it for one more otpLockout.
+	otpLockout = 15 * time.Minute
+)
+
+var (
+	errOtpCooldown     = errors.New("an OTP was sent recently, wait before requesting another")
+	errOtpInvalid      = errors.New("invalid OTP or email not found")
+	errOtpExpired      = errors.New("OTP has expired, request a new one")
+	errOtpTooManyTries = errors.New("too many failed attempts, try again later")
+)
+
+// otpErrorStatus maps OTP errors to a response code.
+func otpErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errOtpCooldown), errors.Is(err, errOtpTooManyTries):
+		return 429
+	case errors.Is(err, errOtpInvalid):
+		return 404
+	case errors.Is(err, errOtpExpired):
+		return 410
+	default:
+		return 500
+	}
+}
+
+// setRetryAfter tells the client how many seconds to wait before trying
+// again.
+func setRetryAfter(c *gin.Context, wait time.Duration) {
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	}
+}
+
+// otpLockRemaining is how long userLogin stays locked after too many wrong
+// codes.
+func otpLockRemaining(userLogin models.UserLogin, now time.Time) time.Duration {
+	if userLogin.OTPLockedUntil == nil {
+		return 0
+	}
+	return max(userLogin.OTPLockedUntil.Sub(now), 0)
+}
+
+// lockUserLogin fetches the login row of email for update, so concurrent
+// sends and guesses see each other's changes.
+func lockUserLogin(tx *gorm.DB, email string) (models.UserLogin, error) {
+	var userLogin models.UserLogin
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("email = ?", email).First(&userLogin).Error
+	return userLogin, err
+}
+
+// renewOtp replaces the OTP of userLogin with the one hashed as hash, unless
+// the email is locked or the last OTP was sent less than otpResendCooldown
+// ago; retryAfter then says how long to wait. Wrong codes are still counted
+// after a resend.
+func renewOtp(userLogin *models.UserLogin, hash string, now time.Time) (retryAfter time.Duration, err error) {
+	if wait := otpLockRemaining(*userLogin, now); wait > 0 {
+		return wait, errOtpTooManyTries
+	}
+	if userLogin.OTPSentAt != nil {
+		if wait := userLogin.OTPSentAt.Add(otpResendCooldown).Sub(now); wait > 0 {
+			return wait, errOtpCooldown
+		}
+	}
+
+	expiresAt := now.Add(otpTTL)
+	userLogin.OTPHash = hash
+	userLogin.OTPExpiresAt = &expiresAt
+	userLogin.OTPSentAt = &now
+	userLogin.Verified = false
+	return 0, nil
+}
+
+// issueOtp stores a fresh OTP for email, replacing any previous one, and
+// returns it. retryAfter is set when renewOtp refuses.
+func issueOtp(email string, now time.Time) (otp uint, retryAfter time.Duration, err error) {
+	otp = utils.RandomNumberGenerate()
+	hash, err := utils.HashOtp(email, otp)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	userLogin, err := lockUserLogin(tx, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		userLogin = models.UserLogin{Email: email}
+		err = nil
+	}
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+	if retryAfter, err := renewOtp(&userLogin, hash, now); err != nil {
+		tx.Rollback()
+		return 0, retryAfter, err
+	}
+	if err := tx.Save(&userLogin).Error; err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+	return otp, 0, tx.Commit().Error
+}
+
+// withdrawOtp forgets an OTP whose email could not be sent, so the cooldown
+// does not keep the user from asking again.
+func withdrawOtp(email string) error {
+	return database.DB.Model(&models.UserLogin{}).Where("email = ?", email).
+		Updates(map[string]interface{}{
+			"otp_hash":       nil,
+			"otp_expires_at": nil,
+			"otp_sent_at":    nil,
+		}).Error
+}
+
+// guessOtp checks otp against the OTP of userLogin. A correct code is
+// consumed and clears the count of wrong ones. A wrong one is counted; every
+// maxOtpAttempts in a row burn the code and lock the email.
+func guessOtp(userLogin *models.UserLogin, email string, otp uint, now time.Time) error {
+	if otpLockRemaining(*userLogin, now) > 0 {
+		return errOtpTooManyTries
+	}
+	if userLogin.OTPHash == "" || userLogin.OTPExpiresAt == nil || !now.Before(*userLogin.OTPExpiresAt) {
+		return errOtpExpired
+	}
+
+	if !utils.CompareOtp(userLogin.OTPHash, email, otp) {
+		userLogin.OTPAttempts++
+		if userLogin.OTPAttempts%maxOtpAttempts != 0 {
+			return errOtpInvalid
+		}
+		lockedUntil := now.Add(otpLockout * time.Duration(userLogin.OTPAttempts/maxOtpAttempts))
+		userLogin.OTPHash = ""
+		userLogin.OTPExpiresAt = nil
+		userLogin.OTPLockedUntil = &lockedUntil
+		return errOtpTooManyTries
+	}
+
+	userLogin.OTPHash = ""
+	userLogin.OTPExpiresAt = nil
+	userLogin.OTPAttempts = 0
+	userLogin.OTPLockedUntil = nil
+	userLogin.Verified = true
+	return nil
+}
+
+// checkOtp runs guessOtp on the stored login of email. The row is changed in
+// tx whether the guess is right or wrong, so callers commit even on
+// errOtpInvalid and errOtpTooManyTries.
+func checkOtp(tx *gorm.DB, email string, otp uint, now time.Time) (models.UserLogin, error) {
+	userLogin, err := lockUserLogin(tx, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return userLogin, errOtpInvalid
+	}
+	if err != nil {
+		return userLogin, err
+	}
+
+	guessErr := guessOtp(&userLogin, email, otp, now)
+	if err := tx.Model(&userLogin).Select("otp_hash", "otp_expires_at", "otp_attempts", "otp_locked_until", "verified").
+		Updates(&userLogin).Error; err != nil {
+		return userLogin, err
+	}
+	return userLogin, guessErr
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"auth_service/internal/models"
+	"auth_service/internal/utils"
+	"errors"
+	"testing"
+	"time"
+)
+
+const testOtpEmail = "otp@example.com"
+
+// pendingOtp returns a login whose OTP code was sent at sentAt.
+func pendingOtp(t *testing.T, code uint, sentAt time.Time) models.UserLogin {
+	t.Helper()
+	hash, err := utils.HashOtp(testOtpEmail, code)
+	if err != nil {
+		t.Fatalf("HashOtp() error = %v", err)
+	}
+	userLogin := models.UserLogin{Email: testOtpEmail}
+	if _, err := renewOtp(&userLogin, hash, sentAt); err != nil {
+		t.Fatalf("renewOtp() error = %v", err)
+	}
+	return userLogin
+}
+
+func TestGuessOtp(t *testing.T) {
+	const code = 123456
+	sentAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		guesses   []uint
+		at        time.Duration
+		wantErrs  []error
+		wantValid bool
+	}{
+		{"correct", []uint{code}, time.Minute, []error{nil}, true},
+		{"expired", []uint{code}, otpTTL, []error{errOtpExpired}, false},
+		{"wrong then correct", []uint{1, code}, time.Minute, []error{errOtpInvalid, nil}, true},
+		{"single use", []uint{code, code}, time.Minute, []error{nil, errOtpExpired}, true},
+		{
+			name:     "attempt limit burns the code",
+			guesses:  []uint{1, 2, 3, 4, 5, code},
+			at:       time.Minute,
+			wantErrs: []error{errOtpInvalid, errOtpInvalid, errOtpInvalid, errOtpInvalid, errOtpTooManyTries, errOtpTooManyTries},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userLogin := pendingOtp(t, code, sentAt)
+			for i, guess := range tt.guesses {
+				err := guessOtp(&userLogin, testOtpEmail, guess, sentAt.Add(tt.at))
+				if !errors.Is(err, tt.wantErrs[i]) {
+					t.Fatalf("guess %d: error = %v, want %v", i+1, err, tt.wantErrs[i])
+				}
+			}
+			if userLogin.Verified != tt.wantValid {
+				t.Errorf("Verified = %v, want %v", userLogin.Verified, tt.wantValid)
+			}
+		})
+	}
+}
+
+func TestOtpLockoutSurvivesResend(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	userLogin := pendingOtp(t, 111111, now)
+
+	guessWrong := func(times int) error {
+		var err error
+		for i := 0; i < times; i++ {
+			err = guessOtp(&userLogin, testOtpEmail, 999999, now)
+		}
+		return err
+	}
+	resend := func(code uint) (time.Duration, error) {
+		hash, err := utils.HashOtp(testOtpEmail, code)
+		if err != nil {
+			t.Fatalf("HashOtp() error = %v", err)
+		}
+		return renewOtp(&userLogin, hash, now)
+	}
+
+	// A resend does not give the guesser a fresh set of attempts.
+	if err := guessWrong(maxOtpAttempts - 1); !errors.Is(err, errOtpInvalid) {
+		t.Fatalf("error = %v, want %v", err, errOtpInvalid)
+	}
+	now = now.Add(otpResendCooldown)
+	if _, err := resend(222222); err != nil {
+		t.Fatalf("resend error = %v", err)
+	}
+	if err := guessWrong(1); !errors.Is(err, errOtpTooManyTries) {
+		t.Fatalf("error = %v, want %v", err, errOtpTooManyTries)
+	}
+
+	// Locked: neither the right code nor a resend gets through.
+	if err := guessOtp(&userLogin, testOtpEmail, 222222, now); !errors.Is(err, errOtpTooManyTries) {
+		t.Fatalf("guess while locked: error = %v, want %v", err, errOtpTooManyTries)
+	}
+	if wait, err := resend(333333); !errors.Is(err, errOtpTooManyTries) || wait != otpLockout {
+		t.Fatalf("resend while locked = %v, %v, want %v, %v", wait, err, otpLockout, errOtpTooManyTries)
+	}
+
+	// The next run of failures locks the email for longer.
+	now = now.Add(otpLockout)
+	if _, err := resend(444444); err != nil {
+		t.Fatalf("resend after lockout error = %v", err)
+	}
+	if err := guessWrong(maxOtpAttempts); !errors.Is(err, errOtpTooManyTries) {
+		t.Fatalf("error = %v, want %v", err, errOtpTooManyTries)
+	}
+	if wait := otpLockRemaining(userLogin, now); wait != 2*otpLockout {
+		t.Errorf("second lockout = %v, want %v", wait, 2*otpLockout)
+	}
+
+	// A correct code clears the count.
+	now = now.Add(2 * otpLockout)
+	if _, err := resend(555555); err != nil {
+		t.Fatalf("resend after second lockout error = %v", err)
+	}
+	if err := guessOtp(&userLogin, testOtpEmail, 555555, now); err != nil {
+		t.Fatalf("correct guess error = %v", err)
+	}
+	if userLogin.OTPAttempts != 0 || userLogin.OTPLockedUntil != nil {
+		t.Errorf("attempts = %d, locked until %v, want both cleared", userLogin.OTPAttempts, userLogin.OTPLockedUntil)
+	}
+}
+
+func TestRenewOtpCooldown(t *testing.T) {
+	sentAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	userLogin := pendingOtp(t, 123456, sentAt)
+
+	wait, err := renewOtp(&userLogin, "hash", sentAt.Add(20*time.Second))
+	if !errors.Is(err, errOtpCooldown) || wait != otpResendCooldown-20*time.Second {
+		t.Fatalf("renewOtp() = %v, %v, want %v, %v", wait, err, otpResendCooldown-20*time.Second, errOtpCooldown)
+	}
+	if _, err := renewOtp(&userLogin, "hash", sentAt.Add(otpResendCooldown)); err != nil {
+		t.Fatalf("renewOtp() after the cooldown error = %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"auth_service/internal/database"
+	"auth_service/internal/models"
+	"auth_service/internal/utils"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type refreshTokenResponse struct {
+	utils.Response
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken trades a refresh token for a new access token and a new
+// refresh token. The old refresh token stops working.
+func RefreshToken(c *gin.Context) {
+	var request refreshTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid request format",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	current, refreshToken, err := rotateRefreshToken(tx, request.RefreshToken, now)
+	if errors.Is(err, errRefreshTokenInvalid) || errors.Is(err, errRefreshTokenReused) {
+		// Keep the family revocation of a reused token.
+		if commitErr := tx.Commit().Error; commitErr != nil {
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server Error",
+				Error:   "Failed to commit transaction",
+			})
+			return
+		}
+		c.JSON(401, utils.Response{
+			Code:    401,
+			Success: false,
+			Message: "Unauthorized",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to rotate refresh token",
+		})
+		return
+	}
+
+	var user models.User
+	if err := tx.Where("id = ?", current.UserID).First(&user).Error; err != nil {
+		tx.Rollback()
+		c.JSON(401, utils.Response{
+			Code:    401,
+			Success: false,
+			Message: "Unauthorized",
+			Error:   "User not found",
+		})
+		return
+	}
+
+	token, err := utils.GenerateToken(user.Email, user.ID, current.FamilyID, accessTokenTTL)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to generate JWT token",
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to commit transaction",
+		})
+		return
+	}
+
+	c.JSON(200, refreshTokenResponse{
+		Response: utils.Response{
+			Code:    200,
+			Success: true,
+			Message: "Token refreshed",
+		},
+		Token:        token,
+		RefreshToken: refreshToken,
+	})
+}
@@ -2,15 +2,15 @@ package handlers
 
 import (
 	"auth_service/internal/database"
+	"auth_service/internal/mailer"
 	"auth_service/internal/models"
 	"auth_service/internal/utils"
+	"context"
 	"fmt"
-	"net/smtp"
-	"os"
-	"strings"
+	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 type SendOtpRequest struct {
@@ -36,8 +36,25 @@ func SendOtp(c *gin.Context) {
 		return
 	}
 
-	otp := utils.RandomNumberGenerate()
-	if err := sendOtpEmail(request.Email, otp); err != nil {
+	otp, retryAfter, err := issueOtp(request.Email, time.Now())
+	if err != nil {
+		status := otpErrorStatus(err)
+		setRetryAfter(c, retryAfter)
+		c.JSON(status, SendOtpResponse{
+			Response: utils.Response{
+				Code:    status,
+				Success: false,
+				Message: "Failed to issue OTP",
+				Error:   err.Error(),
+			},
+		})
+		return
+	}
+
+	if err := sendOtpEmail(c.Request.Context(), request.Email, c.GetHeader("Accept-Language"), otp); err != nil {
+		if err := withdrawOtp(request.Email); err != nil {
+			log.Printf("failed to withdraw unsent OTP: %v", err)
+		}
 		response := utils.Response{
 			Code:    500,
 			Success: false,
@@ -63,80 +80,22 @@ func SendOtp(c *gin.Context) {
 
 }
 
-func sendOtpEmail(recipientEmail string, otp uint) error {
-
-	senderEmail := os.Getenv("EMAIL")
-	senderPassword := os.Getenv("EMAIL_PASSWORD")
-	senderName := os.Getenv("SENDER_NAME")
-	if senderEmail == "" || senderPassword == "" || senderName == "" {
-		return fmt.Errorf("email or password or sender name not set in environment variables")
+// sendOtpEmail mails the code in the language of the account, or of the
+// request when the address has no account yet.
+func sendOtpEmail(ctx context.Context, recipientEmail, acceptLanguage string, otp uint) error {
+	locale := acceptLanguage
+	var user models.User
+	if err := database.DB.Select("locale").Where("email = ?", recipientEmail).First(&user).Error; err == nil {
+		locale = user.Locale
 	}
-	const CONFIG_SMTP_HOST = "smtp.gmail.com"
-	const CONFIG_SMTP_PORT = 587
-	CONFIG_SENDER_NAME := fmt.Sprintf("ZTA chat <%s>", senderEmail)
-	CONFIG_AUTH_EMAIL := senderEmail
-	CONFIG_AUTH_PASSWORD := senderPassword
-
-	to := []string{recipientEmail}
-	subject := "Your OTP Code"
-	message := fmt.Sprintf("Hello,\n\nYour OTP code is: %d\n\nBest regards,\n%s", otp, senderName)
 
-	if err := SendEmail(CONFIG_SMTP_HOST, CONFIG_SMTP_PORT, CONFIG_SENDER_NAME, CONFIG_AUTH_EMAIL, CONFIG_AUTH_PASSWORD, to, nil, subject, message); err != nil {
+	if err := sendMail(ctx, recipientEmail, locale, mailer.TemplateOtp, mailer.OtpData{
+		AppName:          appName(),
+		Code:             fmt.Sprintf("%06d", otp),
+		ExpiresInMinutes: int(otpTTL.Minutes()),
+	}); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
-	tx := database.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	var userLogin models.UserLogin
-	if err := tx.Where("email = ?", recipientEmail).First(&userLogin).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			userLogin = models.UserLogin{
-				Email:    recipientEmail,
-				OTP:      otp,
-				Verified: false,
-			}
-			if err := tx.Create(&userLogin).Error; err != nil {
-				tx.Rollback()
-				return fmt.Errorf("failed to save OTP to database: %w", err)
-			}
-		} else {
-			tx.Rollback()
-			return fmt.Errorf("failed to query database: %w", err)
-		}
-	} else {
-		userLogin.OTP = otp
-		userLogin.Verified = false 
-		if err := tx.Save(&userLogin).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to update OTP in database: %w", err)
-		}
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	return nil
-}
-
-func SendEmail(smtpHost string, smtpPort int, senderName, authEmail, authPassword string, to, cc []string, subject, message string) error {
-	body := "From: " + senderName + "\n" +
-		"To: " + strings.Join(to, ",") + "\n" +
-		"Cc: " + strings.Join(cc, ",") + "\n" +
-		"Subject: " + subject + "\n\n" +
-		message
-
-	auth := smtp.PlainAuth("", authEmail, authPassword, smtpHost)
-	smtpAddr := fmt.Sprintf("%s:%d", smtpHost, smtpPort)
-
-	err := smtp.SendMail(smtpAddr, auth, authEmail, append(to, cc...), []byte(body))
-	if err != nil {
-		return err
-	}
 
 	return nil
 }
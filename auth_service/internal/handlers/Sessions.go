@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"auth_service/internal/database"
+	"auth_service/internal/models"
+	"auth_service/internal/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// sessionTouchInterval limits how often LastUsedAt is written.
+	sessionTouchInterval = time.Minute
+	maxDeviceNameLength  = 100
+
+	// revokedSessionsPerNotify keeps revocation notifications well under
+	// Postgres' 8000 byte payload limit.
+	revokedSessionsPerNotify = 100
+)
+
+var errSessionRevoked = errors.New("session has been revoked or has expired")
+
+// deviceName is what a session is listed as: the name the client gave, or
+// its user agent.
+func deviceName(device, userAgent string) string {
+	device = strings.Join(strings.Fields(device), " ")
+	if device == "" {
+		device = userAgent
+	}
+	if device == "" {
+		device = "Unknown device"
+	}
+	if utf8.RuneCountInString(device) > maxDeviceNameLength {
+		device = string([]rune(device)[:maxDeviceNameLength])
+	}
+	return device
+}
+
+// startSession opens a session for userID on the device making the request.
+func startSession(tx *gorm.DB, c *gin.Context, userID uint, device string, now time.Time) (models.Session, error) {
+	sessionID, err := utils.GenerateOpaqueToken(16)
+	if err != nil {
+		return models.Session{}, err
+	}
+	session := models.Session{
+		SessionID:  sessionID,
+		UserID:     userID,
+		Device:     deviceName(device, c.Request.UserAgent()),
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(refreshTokenTTL),
+	}
+	return session, tx.Create(&session).Error
+}
+
+// loadActiveSession returns the live session that decoded token claims
+// belong to and marks it as used. Tokens without a session are rejected.
+func loadActiveSession(db *gorm.DB, claims map[string]interface{}, now time.Time) (models.Session, error) {
+	var session models.Session
+	sessionID, _ := claims["jti"].(string)
+	userID, _ := claims["user_id"].(uint)
+	if sessionID == "" {
+		return session, errSessionRevoked
+	}
+
+	if err := db.Where("session_id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return session, errSessionRevoked
+		}
+		return session, err
+	}
+	if session.RevokedAt != nil || !now.Before(session.ExpiresAt) {
+		return session, errSessionRevoked
+	}
+
+	if now.Sub(session.LastUsedAt) >= sessionTouchInterval {
+		if err := db.Model(&session).Update("last_used_at", now).Error; err != nil {
+			log.Printf("failed to update session last use: %v", err)
+		}
+	}
+	return session, nil
+}
+
+// authenticate checks the access token of the request and its session.
+// When it fails the error response has already been written.
+func authenticate(c *gin.Context) (models.Session, map[string]interface{}, bool) {
+	authHeader := c.Request.Header.Get("Authorization")
+	if len(authHeader) < 7 || authHeader[:7] != "Bearer " {
+		c.JSON(401, utils.Response{
+			Code:    401,
+			Success: false,
+			Message: "Unauthorized",
+			Error:   "Invalid Authorization header format",
+		})
+		return models.Session{}, nil, false
+	}
+	claims, err := utils.DecodeJWT(authHeader[7:])
+	if err != nil {
+		c.JSON(401, utils.Response{
+			Code:    401,
+			Success: false,
+			Message: "Unauthorized",
+			Error:   "Invalid JWT token",
+		})
+		return models.Session{}, nil, false
+	}
+
+	session, err := loadActiveSession(database.DB, claims, time.Now())
+	if errors.Is(err, errSessionRevoked) {
+		c.JSON(401, utils.Response{
+			Code:    401,
+			Success: false,
+			Message: "Unauthorized",
+			Error:   err.Error(),
+		})
+		return session, nil, false
+	}
+	if err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   "Failed to load session",
+		})
+		return session, nil, false
+	}
+	return session, claims, true
+}
+
+// revokeSessions ends the given sessions together with their refresh tokens,
+// and has chat_service close their sockets once tx commits.
+func revokeSessions(tx *gorm.DB, sessionIDs []string, now time.Time) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	if err := tx.Model(&models.Session{}).
+		Where("session_id IN ? AND revoked_at IS NULL", sessionIDs).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.RefreshToken{}).
+		Where("family_id IN ? AND revoked_at IS NULL", sessionIDs).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return notifySessionsRevoked(tx, sessionIDs)
+}
+
+// chatEventsChannel is the channel chat_service listens on for other
+// services' events. It must match CHAT_EVENTS_CHANNEL there.
+func chatEventsChannel() string {
+	if channel := os.Getenv("CHAT_EVENTS_CHANNEL"); channel != "" {
+		return channel
+	}
+	return "chat_service_events"
+}
+
+// revokedSessionsPayloads splits sessionIDs into the payloads of the
+// notifications chat_service reads, at most revokedSessionsPerNotify ids
+// each.
+func revokedSessionsPayloads(sessionIDs []string) ([]string, error) {
+	var payloads []string
+	for start := 0; start < len(sessionIDs); start += revokedSessionsPerNotify {
+		end := min(start+revokedSessionsPerNotify, len(sessionIDs))
+		payload, err := json.Marshal(map[string]interface{}{
+			"m": map[string]interface{}{"revoked_sessions": sessionIDs[start:end]},
+		})
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, string(payload))
+	}
+	return payloads, nil
+}
+
+// notifySessionsRevoked tells chat_service which sessions were logged out.
+// NOTIFY is transactional, so nothing is sent if tx rolls back.
+func notifySessionsRevoked(tx *gorm.DB, sessionIDs []string) error {
+	payloads, err := revokedSessionsPayloads(sessionIDs)
+	if err != nil {
+		return err
+	}
+	for _, payload := range payloads {
+		if err := tx.Exec("SELECT pg_notify(?, ?)", chatEventsChannel(), payload).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"auth_service/internal/database"
+	"auth_service/internal/models"
+	"auth_service/internal/utils"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestDeviceName(t *testing.T) {
+	long := strings.Repeat("é", maxDeviceNameLength+10)
+	tests := []struct {
+		device, userAgent, want string
+	}{
+		{"  My   phone ", "Mozilla/5.0", "My phone"},
+		{"", "Mozilla/5.0", "Mozilla/5.0"},
+		{" \t ", "Mozilla/5.0", "Mozilla/5.0"},
+		{"", "", "Unknown device"},
+		{long, "", strings.Repeat("é", maxDeviceNameLength)},
+		{"", long, strings.Repeat("é", maxDeviceNameLength)},
+	}
+	for _, tt := range tests {
+		if got := deviceName(tt.device, tt.userAgent); got != tt.want {
+			t.Errorf("deviceName(%q, %q) = %q, want %q", tt.device, tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestRevokedSessionsPayloads(t *testing.T) {
+	if payloads, err := revokedSessionsPayloads(nil); err != nil || len(payloads) != 0 {
+		t.Errorf("no sessions = %q, %v; want no payloads", payloads, err)
+	}
+
+	ids := make([]string, 2*revokedSessionsPerNotify+1)
+	for i := range ids {
+		id, err := utils.GenerateOpaqueToken(16)
+		if err != nil {
+			t.Fatalf("GenerateOpaqueToken: %v", err)
+		}
+		ids[i] = id
+	}
+
+	payloads, err := revokedSessionsPayloads(ids)
+	if err != nil {
+		t.Fatalf("revokedSessionsPayloads: %v", err)
+	}
+	if len(payloads) != 3 {
+		t.Fatalf("got %d payloads, want 3", len(payloads))
+	}
+
+	var seen []string
+	for _, payload := range payloads {
+		// Postgres rejects NOTIFY payloads of 8000 bytes or more.
+		if len(payload) >= 8000 {
+			t.Errorf("payload of %d bytes is too large", len(payload))
+		}
+		var decoded struct {
+			M struct {
+				RevokedSessions []string `json:"revoked_sessions"`
+			} `json:"m"`
+		}
+		if err := json.Unmarshal([]byte(payload), &decoded); err != nil {
+			t.Fatalf("payload %q: %v", payload, err)
+		}
+		if n := len(decoded.M.RevokedSessions); n == 0 || n > revokedSessionsPerNotify {
+			t.Errorf("payload carries %d sessions", n)
+		}
+		seen = append(seen, decoded.M.RevokedSessions...)
+	}
+	if !slices.Equal(seen, ids) {
+		t.Error("payloads do not carry every session exactly once, in order")
+	}
+}
+
+func TestLoadActiveSession(t *testing.T) {
+	setupTestDB(t)
+
+	now := time.Now()
+	live := createTestSession(t, 1, now)
+	expired := createTestSession(t, 1, now.Add(-2*refreshTokenTTL))
+	revoked := createTestSession(t, 1, now)
+	if err := database.DB.Model(&revoked).Update("revoked_at", now).Error; err != nil {
+		t.Fatalf("failed to revoke session: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		claims  map[string]interface{}
+		wantErr error
+	}{
+		{"live", map[string]interface{}{"jti": live.SessionID, "user_id": uint(1)}, nil},
+		{"expired", map[string]interface{}{"jti": expired.SessionID, "user_id": uint(1)}, errSessionRevoked},
+		{"revoked", map[string]interface{}{"jti": revoked.SessionID, "user_id": uint(1)}, errSessionRevoked},
+		{"other user", map[string]interface{}{"jti": live.SessionID, "user_id": uint(2)}, errSessionRevoked},
+		{"no session", map[string]interface{}{"user_id": uint(1)}, errSessionRevoked},
+	}
+	for _, tt := range tests {
+		session, err := loadActiveSession(database.DB, tt.claims, now)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if tt.wantErr == nil && session.SessionID != live.SessionID {
+			t.Errorf("%s: session = %q, want %q", tt.name, session.SessionID, live.SessionID)
+		}
+	}
+}
+
+// withToken runs a single request carrying token through handler.
+func withToken(handler gin.HandlerFunc, token string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/", handler)
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("JWT_TOKEN", "test-secret")
+
+	now := time.Now()
+	session := createTestSession(t, 1, now)
+	token, err := utils.GenerateToken("logout@example.com", session.UserID, session.SessionID, accessTokenTTL)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	var refresh string
+	if err := inTx(t, func(tx *gorm.DB) (err error) {
+		refresh, err = issueRefreshToken(tx, session.UserID, session.SessionID, now)
+		return err
+	}); err != nil {
+		t.Fatalf("issueRefreshToken: %v", err)
+	}
+
+	if w := withToken(VerifyTokenClaims, token); w.Code != 200 {
+		t.Fatalf("before logout: status = %d: %s", w.Code, w.Body.String())
+	}
+	if w := withToken(LogoutSession, token); w.Code != 200 {
+		t.Fatalf("logout: status = %d: %s", w.Code, w.Body.String())
+	}
+	if w := withToken(VerifyTokenClaims, token); w.Code != 401 {
+		t.Errorf("after logout: status = %d, want 401", w.Code)
+	}
+	if row := loadRefreshToken(t, refresh); row.RevokedAt == nil {
+		t.Error("logout left the refresh token usable")
+	}
+
+	var stored models.Session
+	if err := database.DB.First(&stored, session.ID).Error; err != nil {
+		t.Fatalf("failed to reload session: %v", err)
+	}
+	if stored.RevokedAt == nil {
+		t.Error("session was not revoked")
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"auth_service/internal/models"
+	"auth_service/internal/utils"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	errRefreshTokenInvalid = errors.New("invalid or expired refresh token")
+	errRefreshTokenReused  = errors.New("refresh token was already used; the session was revoked")
+)
+
+// issueRefreshToken stores a new refresh token of familyID for userID and
+// returns it. The family of a session is its SessionID.
+func issueRefreshToken(tx *gorm.DB, userID uint, familyID string, now time.Time) (string, error) {
+	token, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Create(&models.RefreshToken{
+		TokenHash: utils.HashToken(token),
+		UserID:    userID,
+		FamilyID:  familyID,
+		ExpiresAt: now.Add(refreshTokenTTL),
+	}).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// rotateRefreshToken consumes token, issues its successor and extends the
+// session of its family. A token that was already used means it leaked, so
+// the whole session is revoked; the caller must commit tx even then for the
+// revocation to stick.
+func rotateRefreshToken(tx *gorm.DB, token string, now time.Time) (models.RefreshToken, string, error) {
+	var current models.RefreshToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ?", utils.HashToken(token)).
+		First(&current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return current, "", errRefreshTokenInvalid
+		}
+		return current, "", err
+	}
+
+	if current.RevokedAt != nil || !now.Before(current.ExpiresAt) {
+		return current, "", errRefreshTokenInvalid
+	}
+	if current.UsedAt != nil {
+		if err := revokeSessions(tx, []string{current.FamilyID}, now); err != nil {
+			return current, "", err
+		}
+		return current, "", errRefreshTokenReused
+	}
+
+	if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+		return current, "", err
+	}
+	result := tx.Model(&models.Session{}).
+		Where("session_id = ? AND revoked_at IS NULL", current.FamilyID).
+		Updates(map[string]interface{}{
+			"last_used_at": now,
+			"expires_at":   now.Add(refreshTokenTTL),
+		})
+	if result.Error != nil {
+		return current, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return current, "", errRefreshTokenInvalid
+	}
+	next, err := issueRefreshToken(tx, current.UserID, current.FamilyID, now)
+	return current, next, err
+}
+
+// deleteExpiredRefreshTokens drops tokens nobody can use any more. Used tokens
+// are kept until they expire so reuse can still be detected.
+func deleteExpiredRefreshTokens(tx *gorm.DB, now time.Time) error {
+	return tx.Unscoped().Where("expires_at < ?", now).Delete(&models.RefreshToken{}).Error
+}
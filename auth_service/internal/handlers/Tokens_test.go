@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"auth_service/internal/database"
+	"auth_service/internal/models"
+	"auth_service/internal/utils"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// inTx runs fn in a transaction that is committed whatever fn returns, as
+// RefreshToken does so that a revocation sticks.
+func inTx(t *testing.T, fn func(tx *gorm.DB) error) error {
+	t.Helper()
+	tx := database.DB.Begin()
+	err := fn(tx)
+	if commitErr := tx.Commit().Error; commitErr != nil {
+		t.Fatalf("failed to commit: %v", commitErr)
+	}
+	return err
+}
+
+func loadRefreshToken(t *testing.T, token string) models.RefreshToken {
+	t.Helper()
+	var row models.RefreshToken
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(token)).First(&row).Error; err != nil {
+		t.Fatalf("failed to load refresh token: %v", err)
+	}
+	return row
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	setupTestDB(t)
+
+	now := time.Now().Truncate(time.Microsecond)
+	session := createTestSession(t, 1, now.Add(-time.Hour))
+
+	var first string
+	if err := inTx(t, func(tx *gorm.DB) (err error) {
+		first, err = issueRefreshToken(tx, session.UserID, session.SessionID, now.Add(-time.Hour))
+		return err
+	}); err != nil {
+		t.Fatalf("issueRefreshToken: %v", err)
+	}
+
+	var second string
+	if err := inTx(t, func(tx *gorm.DB) error {
+		current, next, err := rotateRefreshToken(tx, first, now)
+		if current.FamilyID != session.SessionID {
+			t.Errorf("family = %q, want %q", current.FamilyID, session.SessionID)
+		}
+		second = next
+		return err
+	}); err != nil {
+		t.Fatalf("rotateRefreshToken: %v", err)
+	}
+	if second == "" || second == first {
+		t.Fatalf("successor = %q, want a new token", second)
+	}
+	if used := loadRefreshToken(t, first); used.UsedAt == nil {
+		t.Error("rotated token is not marked used")
+	}
+	if next := loadRefreshToken(t, second); next.FamilyID != session.SessionID || next.UsedAt != nil {
+		t.Errorf("successor = %+v, want an unused token of the same family", next)
+	}
+	var extended models.Session
+	if err := database.DB.First(&extended, session.ID).Error; err != nil {
+		t.Fatalf("failed to reload session: %v", err)
+	}
+	if !extended.ExpiresAt.Equal(now.Add(refreshTokenTTL)) || !extended.LastUsedAt.Equal(now) {
+		t.Errorf("session = expires %v, used %v; want it extended from %v", extended.ExpiresAt, extended.LastUsedAt, now)
+	}
+
+	// Presenting the used token again revokes the whole family.
+	err := inTx(t, func(tx *gorm.DB) error {
+		_, _, err := rotateRefreshToken(tx, first, now.Add(time.Minute))
+		return err
+	})
+	if !errors.Is(err, errRefreshTokenReused) {
+		t.Fatalf("reuse = %v, want errRefreshTokenReused", err)
+	}
+	var revoked models.Session
+	if err := database.DB.First(&revoked, session.ID).Error; err != nil {
+		t.Fatalf("failed to reload session: %v", err)
+	}
+	if revoked.RevokedAt == nil {
+		t.Error("session was not revoked on reuse")
+	}
+	if next := loadRefreshToken(t, second); next.RevokedAt == nil {
+		t.Error("successor token was not revoked on reuse")
+	}
+	err = inTx(t, func(tx *gorm.DB) error {
+		_, _, err := rotateRefreshToken(tx, second, now.Add(time.Minute))
+		return err
+	})
+	if !errors.Is(err, errRefreshTokenInvalid) {
+		t.Errorf("rotating a revoked token = %v, want errRefreshTokenInvalid", err)
+	}
+}
+
+func TestRotateRefreshTokenRejectsExpiredAndUnknown(t *testing.T) {
+	setupTestDB(t)
+
+	now := time.Now()
+	session := createTestSession(t, 1, now)
+
+	var token string
+	if err := inTx(t, func(tx *gorm.DB) (err error) {
+		token, err = issueRefreshToken(tx, session.UserID, session.SessionID, now.Add(-refreshTokenTTL-time.Minute))
+		return err
+	}); err != nil {
+		t.Fatalf("issueRefreshToken: %v", err)
+	}
+
+	for name, presented := range map[string]string{"expired": token, "unknown": "not-a-token"} {
+		err := inTx(t, func(tx *gorm.DB) error {
+			_, _, err := rotateRefreshToken(tx, presented, now)
+			return err
+		})
+		if !errors.Is(err, errRefreshTokenInvalid) {
+			t.Errorf("%s token = %v, want errRefreshTokenInvalid", name, err)
+		}
+	}
+
+	var live models.Session
+	if err := database.DB.First(&live, session.ID).Error; err != nil {
+		t.Fatalf("failed to reload session: %v", err)
+	}
+	if live.RevokedAt != nil {
+		t.Error("an expired token revoked its session")
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"auth_service/internal/database"
+	"auth_service/internal/mailer"
+	"auth_service/internal/models"
+	"auth_service/internal/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type updateLocaleRequest struct {
+	Locale string `json:"locale" binding:"required"`
+}
+
+type updateLocaleResponse struct {
+	utils.Response
+	Locale string `json:"locale"`
+}
+
+// UpdateLocale sets the language of the caller's account emails. The
+// requested locale is matched to the closest supported one, which is
+// returned.
+func UpdateLocale(c *gin.Context) {
+	var request updateLocaleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid request format",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	_, claims, ok := authenticate(c)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("email = ?", claims["email"]).First(&user).Error; err != nil {
+		c.JSON(404, utils.Response{
+			Code:    404,
+			Success: false,
+			Message: "User not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	locale := mailer.MatchLocale(request.Locale)
+	if locale != user.Locale {
+		if err := database.DB.Model(&user).Update("locale", locale).Error; err != nil {
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server Error",
+				Error:   "Failed to update locale",
+			})
+			return
+		}
+		notify(user.Email, locale, mailer.TemplateAccountChanged, mailer.AccountChangedData{
+			AppName: appName(),
+			Change:  "locale",
+			Time:    formatMailTime(time.Now()),
+		})
+	}
+
+	c.JSON(200, updateLocaleResponse{
+		Response: utils.Response{
+			Code:    200,
+			Success: true,
+			Message: "Locale updated",
+		},
+		Locale: locale,
+	})
+}
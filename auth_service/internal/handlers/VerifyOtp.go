@@ -2,9 +2,7 @@ package handlers
 
 import (
 	"auth_service/internal/database"
-	"auth_service/internal/models"
 	"auth_service/internal/utils"
-	"fmt"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -32,9 +30,6 @@ func VerifyOtp(c *gin.Context) {
 		return
 	}
 
-	fmt.Printf("Received OTP verification request for email: %s with OTP: %d\n", request.Email, request.OTP)
-
-	var userLogin models.UserLogin
 	tx := database.DB.Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -47,39 +42,27 @@ func VerifyOtp(c *gin.Context) {
 			})
 		}
 	}()
-	if err := tx.Where("email = ? AND otp = ?", request.Email, request.OTP).First(&userLogin).Error; err != nil {
-		tx.Rollback()
-		c.JSON(404, utils.Response{
-			Code:    404,
-			Success: false,
-			Message: "Invalid OTP or email not found",
-			Error:   "No matching user login found",
-		})
-		return
-	}
-	if err := tx.Where("email = ? AND otp = ?", request.Email, request.OTP).First(&userLogin).Error; err != nil {
-		tx.Rollback()
-		c.JSON(404, utils.Response{
-			Code:    404,
-			Success: false,
-			Message: "Invalid OTP or email not found",
-			Error:   "No matching user login found",
-		})
-		return
-	}
-	if err := tx.Where("email = ? AND otp = ?", request.Email, request.OTP).First(&userLogin).Error; err != nil {
-		tx.Rollback()
-		c.JSON(404, utils.Response{
-			Code:    404,
+	now := time.Now()
+	userLogin, err := checkOtp(tx, request.Email, request.OTP, now)
+	if err != nil {
+		status := otpErrorStatus(err)
+		setRetryAfter(c, otpLockRemaining(userLogin, now))
+		// Failed attempts are counted even though the request fails.
+		if status == 500 {
+			tx.Rollback()
+		} else if commitErr := tx.Commit().Error; commitErr != nil {
+			status, err = 500, commitErr
+		}
+		c.JSON(status, utils.Response{
+			Code:    status,
 			Success: false,
-			Message: "Invalid OTP or email not found",
-			Error:   "No matching user login found",
+			Message: "OTP verification failed",
+			Error:   err.Error(),
 		})
 		return
-
 	}
 
-	token, err := utils.GenerateToken(request.Email, userLogin.ID, 5*time.Minute)
+	token, err := utils.GenerateToken(request.Email, userLogin.ID, "", 5*time.Minute)
 	if err != nil {
 		tx.Rollback()
 
@@ -93,20 +76,6 @@ func VerifyOtp(c *gin.Context) {
 
 	}
 
-	userLogin.Verified = true
-	userLogin.OTP = 0
-	if err := tx.Save(&userLogin).Error; err != nil {
-		tx.Rollback()
-		c.JSON(500, utils.Response{
-			Code:    500,
-			Success: false,
-			Message: "Failed to update user login",
-			Error:   err.Error(),
-		})
-		return
-
-	}
-
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(500, utils.Response{
 			Code:    500,
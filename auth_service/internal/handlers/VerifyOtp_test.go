@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"auth_service/internal/database"
+	"auth_service/internal/mailer"
+	"auth_service/internal/models"
+	"testing"
+	"time"
+)
+
+func TestSendAndVerifyOtp(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("JWT_TOKEN", "test-secret")
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, email string, outbox *mailer.Memory)
+	}{
+		{"resend cooldown", func(t *testing.T, email string, outbox *mailer.Memory) {
+			if w := postJSON(SendOtp, SendOtpRequest{Email: email}); w.Code != 200 {
+				t.Fatalf("first send status = %d: %s", w.Code, w.Body.String())
+			}
+			w := postJSON(SendOtp, SendOtpRequest{Email: email})
+			if w.Code != 429 {
+				t.Fatalf("second send status = %d, want 429", w.Code)
+			}
+			if got := w.Header().Get("Retry-After"); got == "" || got == "0" {
+				t.Errorf("Retry-After = %q, want the remaining cooldown", got)
+			}
+			if n := len(outbox.Sent()); n != 1 {
+				t.Errorf("sent %d emails, want 1", n)
+			}
+		}},
+		{"single use", func(t *testing.T, email string, outbox *mailer.Memory) {
+			postJSON(SendOtp, SendOtpRequest{Email: email})
+			otp := sentOtp(t, outbox)
+			if w := postJSON(VerifyOtp, VerifyOtpRequest{Email: email, OTP: otp}); w.Code != 200 {
+				t.Fatalf("first verify status = %d: %s", w.Code, w.Body.String())
+			}
+			if w := postJSON(VerifyOtp, VerifyOtpRequest{Email: email, OTP: otp}); w.Code != 410 {
+				t.Fatalf("second verify status = %d, want 410", w.Code)
+			}
+		}},
+		{"expired", func(t *testing.T, email string, outbox *mailer.Memory) {
+			postJSON(SendOtp, SendOtpRequest{Email: email})
+			otp := sentOtp(t, outbox)
+			database.DB.Model(&models.UserLogin{}).Where("email = ?", email).
+				Update("otp_expires_at", time.Now().Add(-time.Second))
+			if w := postJSON(VerifyOtp, VerifyOtpRequest{Email: email, OTP: otp}); w.Code != 410 {
+				t.Fatalf("verify status = %d, want 410", w.Code)
+			}
+		}},
+		{"attempt limit", func(t *testing.T, email string, outbox *mailer.Memory) {
+			postJSON(SendOtp, SendOtpRequest{Email: email})
+			otp := sentOtp(t, outbox)
+			wrong := otp%900000 + 100001
+			for i := 1; i < maxOtpAttempts; i++ {
+				if w := postJSON(VerifyOtp, VerifyOtpRequest{Email: email, OTP: wrong}); w.Code != 404 {
+					t.Fatalf("wrong guess %d status = %d, want 404", i, w.Code)
+				}
+			}
+			if w := postJSON(VerifyOtp, VerifyOtpRequest{Email: email, OTP: wrong}); w.Code != 429 {
+				t.Fatalf("last wrong guess status = %d, want 429", w.Code)
+			}
+			w := postJSON(VerifyOtp, VerifyOtpRequest{Email: email, OTP: otp})
+			if w.Code != 429 || w.Header().Get("Retry-After") == "" {
+				t.Fatalf("burnt code status = %d, Retry-After %q, want 429 with a wait", w.Code, w.Header().Get("Retry-After"))
+			}
+		}},
+		{"send failure withdraws the code", func(t *testing.T, email string, outbox *mailer.Memory) {
+			useMailer(t, failingMailer{})
+			if w := postJSON(SendOtp, SendOtpRequest{Email: email}); w.Code != 500 {
+				t.Fatalf("failed send status = %d, want 500", w.Code)
+			}
+			var userLogin models.UserLogin
+			if err := database.DB.Where("email = ?", email).First(&userLogin).Error; err != nil {
+				t.Fatalf("failed to load login: %v", err)
+			}
+			if userLogin.OTPHash != "" || userLogin.OTPSentAt != nil {
+				t.Fatalf("unsent OTP was kept: %+v", userLogin)
+			}
+
+			// Without the cooldown the user can ask again right away.
+			useMailer(t, outbox)
+			if w := postJSON(SendOtp, SendOtpRequest{Email: email}); w.Code != 200 {
+				t.Fatalf("retry status = %d: %s", w.Code, w.Body.String())
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outbox := mailer.NewMemory()
+			useMailer(t, outbox)
+			tt.run(t, testEmail(t), outbox)
+		})
+	}
+}
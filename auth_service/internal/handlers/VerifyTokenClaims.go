@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"auth_service/internal/database"
 	"auth_service/internal/utils"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type verifyTokenClaimsResponse struct {
@@ -12,6 +16,8 @@ type verifyTokenClaimsResponse struct {
 	User utils.JwtClaims `json:"user"`
 }
 
+// VerifyTokenClaims is how the other services authenticate a request. Tokens
+// whose session was logged out are rejected even before they expire.
 func VerifyTokenClaims(c *gin.Context) {
 	headers := c.Request.Header
 
@@ -71,6 +77,21 @@ func VerifyTokenClaims(c *gin.Context) {
 		return
 	}
 
+	session, err := loadActiveSession(database.DB, claims, time.Now())
+	if err != nil {
+		status := 401
+		if !errors.Is(err, errSessionRevoked) {
+			status = 500
+		}
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Unauthorized",
+			Error:   err.Error(),
+		})
+		return
+	}
+
 	c.JSON(200, verifyTokenClaimsResponse{
 		Response: utils.Response{
 			Code:    200,
@@ -80,6 +101,9 @@ func VerifyTokenClaims(c *gin.Context) {
 		User: utils.JwtClaims{
 			Email:  email,
 			UserID: uint(userID),
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID: session.SessionID,
+			},
 		},
 	})
 }
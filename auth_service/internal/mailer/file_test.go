@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	f, err := NewFile(dir, "")
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+
+	for _, subject := range []string{"One", "Two"} {
+		if err := f.Send(context.Background(), Message{To: []string{"a@example.com"}, Subject: subject, Text: "body"}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if err := f.Send(context.Background(), Message{To: []string{"a@example.com"}, Subject: "Bad\r\nBcc: x@example.com"}); err == nil {
+		t.Fatal("Send() accepted a subject with a line break")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("outbox has %d files, want 2", len(entries))
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".eml") {
+			t.Errorf("outbox file %q is not an .eml file", entry.Name())
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		msg := readMessage(t, data)
+		if from := msg.Header.Get("From"); from != `"ZTA chat" <noreply@localhost>` {
+			t.Errorf("From = %q, want the default sender", from)
+		}
+		if to := msg.Header.Get("To"); to != "a@example.com" {
+			t.Errorf("To = %q", to)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errHeaderInjection = errors.New("line breaks are not allowed in mail headers")
+
+// Message is an email to send. The sender is configured on the Mailer. HTML
+// is optional; when set the message carries both versions.
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages. Implementations are safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var Default Mailer
+
+// Init sets Default from MAIL_BACKEND: "smtp" to send through a server, "file"
+// to write messages to MAIL_OUTBOX_DIR for local development, or "memory" to
+// keep them in the process. Without MAIL_BACKEND, SMTP is used when a sender
+// is configured through MAIL_FROM or EMAIL, and the file outbox otherwise.
+func Init() {
+	m, err := fromEnv()
+	if err != nil {
+		log.Fatal("Error configuring mailer: ", err)
+	}
+	Default = m
+}
+
+func fromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" && os.Getenv("EMAIL") != "" {
+		from = fmt.Sprintf("ZTA chat <%s>", os.Getenv("EMAIL"))
+	}
+
+	backend := os.Getenv("MAIL_BACKEND")
+	if backend == "" {
+		backend = "smtp"
+		if from == "" {
+			log.Println("No mail sender configured, writing mail to the outbox directory")
+			backend = "file"
+		}
+	}
+
+	switch backend {
+	case "smtp":
+		return NewSMTP(smtpConfigFromEnv(from))
+	case "file":
+		dir := os.Getenv("MAIL_OUTBOX_DIR")
+		if dir == "" {
+			dir = "./data/outbox"
+		}
+		return NewFile(dir, from)
+	case "memory":
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_BACKEND %q", backend)
+	}
+}
+
+// smtpConfigFromEnv reads SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD
+// and SMTP_TLS, falling back to the Gmail account in EMAIL and
+// EMAIL_PASSWORD that the service used before they existed.
+func smtpConfigFromEnv(from string) SMTPConfig {
+	cfg := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		TLS:      TLSMode(os.Getenv("SMTP_TLS")),
+		From:     from,
+	}
+	if cfg.Host == "" {
+		cfg.Host = "smtp.gmail.com"
+	}
+	if port, err := strconv.Atoi(os.Getenv("SMTP_PORT")); err == nil {
+		cfg.Port = port
+	} else {
+		cfg.Port = 587
+	}
+	if cfg.Username == "" {
+		cfg.Username = os.Getenv("EMAIL")
+	}
+	if cfg.Password == "" {
+		cfg.Password = os.Getenv("EMAIL_PASSWORD")
+	}
+	if cfg.TLS == "" {
+		cfg.TLS = TLSStartTLS
+		if cfg.Port == 465 {
+			cfg.TLS = TLSImplicit
+		}
+	}
+	return cfg
+}
+
+// validate rejects messages that cannot be delivered or would smuggle extra
+// headers through a line break.
+func (m Message) validate() error {
+	if len(m.To) == 0 {
+		return errors.New("message has no recipient")
+	}
+	for _, to := range m.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+	}
+	if strings.ContainsAny(m.Subject, "\r\n") {
+		return errHeaderInjection
+	}
+	return nil
+}
+
+// encode renders the message as it goes over the wire: a text/plain part, or
+// a multipart/alternative one when there is HTML, in quoted-printable UTF-8.
+func (m Message) encode(from string, now time.Time) ([]byte, error) {
+	var b bytes.Buffer
+	header := func(name, value string) {
+		b.WriteString(name + ": " + value + "\r\n")
+	}
+
+	sender := from
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		sender = addr.String()
+		if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
+			domain = addr.Address[at+1:]
+		}
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	header("From", sender)
+	header("To", strings.Join(m.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain))
+	header("MIME-Version", "1.0")
+
+	if m.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		if err := writeQuotedPrintable(&b, m.Text); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	header("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": parts.Boundary()}))
+	b.WriteString("\r\n")
+	b.Write(body.Bytes())
+	return b.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, strings.ReplaceAll(content, "\r\n", "\n")); err != nil {
+		return err
+	}
+	return qp.Close()
+}
@@ -0,0 +1,217 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+func readMessage(t *testing.T, data []byte) *mail.Message {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("encoded message does not parse: %v\n%s", err, data)
+	}
+	return msg
+}
+
+func decodeQP(t *testing.T, r io.Reader) string {
+	t.Helper()
+	body, err := io.ReadAll(quotedprintable.NewReader(r))
+	if err != nil {
+		t.Fatalf("failed to decode quoted-printable body: %v", err)
+	}
+	return string(body)
+}
+
+func TestEncodeHeaders(t *testing.T) {
+	msg := Message{To: []string{"a@example.com", "b@example.com"}, Subject: "Código de verificación", Text: "hi"}
+	data, err := msg.encode("ZTA chat <noreply@zta.example>", testTime)
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+	parsed := readMessage(t, data)
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, msg.Subject)
+	}
+	tests := []struct{ header, want string }{
+		{"From", `"ZTA chat" <noreply@zta.example>`},
+		{"To", "a@example.com, b@example.com"},
+		{"Date", testTime.Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+	}
+	for _, tt := range tests {
+		if got := parsed.Header.Get(tt.header); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+	if id := parsed.Header.Get("Message-ID"); !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@zta.example>") {
+		t.Errorf("Message-ID = %q, want one on the sender's domain", id)
+	}
+}
+
+func TestEncodePlainText(t *testing.T) {
+	text := "Your code is: 123456\r\nIt expires soon. " + strings.Repeat("á", 100)
+	data, err := Message{To: []string{"a@example.com"}, Subject: "Code", Text: text}.encode("noreply@zta.example", testTime)
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+	parsed := readMessage(t, data)
+
+	if got := parsed.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := parsed.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+		t.Errorf("Content-Transfer-Encoding = %q", got)
+	}
+	if body := decodeQP(t, parsed.Body); body != text {
+		t.Errorf("body = %q, want %q", body, text)
+	}
+	for _, line := range strings.Split(string(data), "\r\n") {
+		if len(line) > 78 {
+			t.Errorf("line longer than 78 characters: %q", line)
+		}
+	}
+}
+
+func TestEncodeMultipart(t *testing.T) {
+	msg := Message{To: []string{"a@example.com"}, Subject: "Code", Text: "plain", HTML: "<p>html</p>"}
+	data, err := msg.encode("noreply@zta.example", testTime)
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+	parsed := readMessage(t, data)
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v), want multipart/alternative", parsed.Header.Get("Content-Type"), err)
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		part, err := parts.NextRawPart()
+		if err != nil {
+			t.Fatalf("missing %s part: %v", want.contentType, err)
+		}
+		if got := part.Header.Get("Content-Type"); got != want.contentType {
+			t.Errorf("part Content-Type = %q, want %q", got, want.contentType)
+		}
+		if body := decodeQP(t, part); body != want.body {
+			t.Errorf("%s body = %q, want %q", want.contentType, body, want.body)
+		}
+	}
+	if _, err := parts.NextRawPart(); !errors.Is(err, io.EOF) {
+		t.Errorf("extra part after text and HTML: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     Message
+		wantErr bool
+	}{
+		{"valid", Message{To: []string{"a@example.com"}, Subject: "Hello"}, false},
+		{"no recipient", Message{Subject: "Hello"}, true},
+		{"invalid recipient", Message{To: []string{"not an address"}, Subject: "Hello"}, true},
+		{"CRLF in subject", Message{To: []string{"a@example.com"}, Subject: "Hello\r\nBcc: x@example.com"}, true},
+		{"LF in subject", Message{To: []string{"a@example.com"}, Subject: "Hello\nBcc: x@example.com"}, true},
+		{"CR in subject", Message{To: []string{"a@example.com"}, Subject: "Hello\rBcc: x@example.com"}, true},
+		{"CRLF in recipient", Message{To: []string{"a@example.com\r\nBcc: x@example.com"}, Subject: "Hello"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemory()
+			err := m.Send(context.Background(), tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, want error %v", err, tt.wantErr)
+			}
+			if sent := len(m.Sent()); sent != 0 && tt.wantErr {
+				t.Errorf("rejected message was kept")
+			}
+		})
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	to := []string{"a@example.com"}
+	if err := m.Send(context.Background(), Message{To: to, Subject: "One"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	to[0] = "changed@example.com"
+
+	sent := m.Sent()
+	if len(sent) != 1 || sent[0].Subject != "One" || sent[0].To[0] != "a@example.com" {
+		t.Fatalf("Sent() = %+v", sent)
+	}
+	m.Reset()
+	if len(m.Sent()) != 0 {
+		t.Errorf("Sent() after Reset() is not empty")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	resetEnv := func(t *testing.T) {
+		for _, key := range []string{"MAIL_BACKEND", "MAIL_FROM", "EMAIL", "EMAIL_PASSWORD", "SMTP_HOST", "SMTP_PORT"} {
+			t.Setenv(key, "")
+		}
+		t.Setenv("MAIL_OUTBOX_DIR", t.TempDir())
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"nothing configured", nil, "file", false},
+		{"sender configured", map[string]string{"MAIL_FROM": "noreply@zta.example"}, "smtp", false},
+		{"legacy gmail account", map[string]string{"EMAIL": "me@gmail.com"}, "smtp", false},
+		{"explicit smtp without sender", map[string]string{"MAIL_BACKEND": "smtp"}, "", true},
+		{"memory", map[string]string{"MAIL_BACKEND": "memory"}, "memory", false},
+		{"unknown", map[string]string{"MAIL_BACKEND": "carrier-pigeon"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			m, err := fromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fromEnv() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var got string
+			switch m.(type) {
+			case *SMTP:
+				got = "smtp"
+			case *File:
+				got = "file"
+			case *Memory:
+				got = "memory"
+			}
+			if got != tt.want {
+				t.Errorf("fromEnv() = %T, want %s", m, tt.want)
+			}
+		})
+	}
+}
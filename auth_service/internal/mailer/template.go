@@ -0,0 +1,117 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"path"
+	"strings"
+	texttemplate "text/template"
+
+	"golang.org/x/text/language"
+)
+
+// Template names an email the service sends. Every template exists in every
+// supported locale as <locale>/<name>.txt, defining "subject" and "text",
+// and <locale>/<name>.html.
+type Template string
+
+const (
+	TemplateOtp            Template = "otp"
+	TemplateNewDevice      Template = "new_device"
+	TemplateAccountChanged Template = "account_changed"
+)
+
+// OtpData fills TemplateOtp.
+type OtpData struct {
+	AppName          string
+	Code             string
+	ExpiresInMinutes int
+}
+
+// NewDeviceData fills TemplateNewDevice.
+type NewDeviceData struct {
+	AppName   string
+	Time      string
+	UserAgent string
+	IP        string
+}
+
+// AccountChangedData fills TemplateAccountChanged. Change says what changed,
+// "locale" or "sessions"; the templates word each kind of change.
+type AccountChangedData struct {
+	AppName string
+	Change  string
+	Time    string
+}
+
+// DefaultLocale is used when the user's preference is unknown or has no
+// templates.
+const DefaultLocale = "en"
+
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	supportedLocales = []string{"en", "es"}
+	localeMatcher    = language.NewMatcher([]language.Tag{language.English, language.Spanish})
+
+	textTemplates = map[string]*texttemplate.Template{}
+	htmlTemplates = map[string]*htmltemplate.Template{}
+)
+
+func init() {
+	for _, locale := range supportedLocales {
+		for _, name := range []Template{TemplateOtp, TemplateNewDevice, TemplateAccountChanged} {
+			key := templateKey(locale, name)
+			textTemplates[key] = texttemplate.Must(texttemplate.ParseFS(templateFS, path.Join("templates", key+".txt")))
+			htmlTemplates[key] = htmltemplate.Must(htmltemplate.ParseFS(templateFS, path.Join("templates", key+".html")))
+		}
+	}
+}
+
+func templateKey(locale string, name Template) string {
+	return locale + "/" + string(name)
+}
+
+// MatchLocale picks the supported locale closest to preference, which may be
+// a single tag such as "es-MX" or an Accept-Language header.
+func MatchLocale(preference string) string {
+	tags, _, err := language.ParseAcceptLanguage(preference)
+	if err != nil || len(tags) == 0 {
+		return DefaultLocale
+	}
+	_, index, confidence := localeMatcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLocale
+	}
+	return supportedLocales[index]
+}
+
+// Render fills in a template in the given locale. The returned message has
+// no recipient yet.
+func Render(name Template, locale string, data any) (Message, error) {
+	key := templateKey(MatchLocale(locale), name)
+	text, ok := textTemplates[key]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown mail template %q", name)
+	}
+
+	var subject, body, html bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := text.ExecuteTemplate(&body, "text", data); err != nil {
+		return Message{}, err
+	}
+	if err := htmlTemplates[key].Execute(&html, data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(body.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is an opaque credential that buys a new access token. Only
+// the SHA-256 of the token is stored. Every use replaces it with a new token
+// of the same family; presenting a used token again revokes the family.
+type RefreshToken struct {
+	gorm.Model
+	TokenHash string     `gorm:"uniqueIndex;not null"`
+	UserID    uint       `gorm:"not null;index"`
+	FamilyID  string     `gorm:"not null;index"`
+	ExpiresAt time.Time  `gorm:"not null;index"`
+	UsedAt    *time.Time `gorm:"default:null"`
+	RevokedAt *time.Time `gorm:"default:null"`
+}
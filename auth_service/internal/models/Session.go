@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session is one login of a user on one device. Its SessionID is the jti of
+// every access token issued for it and the family of its refresh tokens, so
+// revoking the session ends both. A session lapses at ExpiresAt unless its
+// refresh token is used before.
+type Session struct {
+	gorm.Model
+	SessionID  string     `gorm:"uniqueIndex;not null"`
+	UserID     uint       `gorm:"not null;index"`
+	Device     string     `gorm:"not null"`
+	IP         string     `gorm:"not null"`
+	UserAgent  string     `gorm:"not null"`
+	LastUsedAt time.Time  `gorm:"not null"`
+	ExpiresAt  time.Time  `gorm:"not null;index"`
+	RevokedAt  *time.Time `gorm:"default:null"`
+}
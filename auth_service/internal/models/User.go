@@ -16,11 +16,33 @@ type User struct {
 	Username     string     `gorm:"uniqueIndex;not null"`
 	Salt         string     `gorm:"not null"`
 	LastLogin    time.Time  `gorm:"default:null"`
+	// Locale picks the language of account emails.
+	Locale       string     `gorm:"not null;default:en"`
 }
 
+// KnownDevice is a browser or app a user has signed in from, identified by a
+// hash of its user agent. Signing in from a new one triggers a notice email.
+type KnownDevice struct {
+	gorm.Model
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_known_device"`
+	DeviceHash string    `gorm:"not null;uniqueIndex:idx_known_device"`
+	LastSeenAt time.Time `gorm:"not null"`
+}
+
+// UserLogin holds the pending OTP of an email address. Only a hash of the
+// code is stored; it stops working at OTPExpiresAt or after too many failed
+// attempts, and OTPSentAt rate-limits resends.
 type UserLogin struct {
 	gorm.Model
-	Email    string `gorm:"uniqueIndex;not null"`
-	OTP      uint   `gorm:"default:null"`
-	Verified bool   `gorm:"default:false"`
+	Email        string     `gorm:"uniqueIndex;not null"`
+	OTPHash      string     `gorm:"default:null"`
+	OTPExpiresAt *time.Time `gorm:"default:null"`
+	OTPSentAt    *time.Time `gorm:"default:null"`
+	Verified     bool       `gorm:"default:false"`
+
+	// OTPAttempts counts wrong codes since the last correct one, across
+	// resends. Too many in a row lock the email until OTPLockedUntil, for
+	// longer each time.
+	OTPAttempts    int        `gorm:"not null;default:0"`
+	OTPLockedUntil *time.Time `gorm:"default:null"`
 }
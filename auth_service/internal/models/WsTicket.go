@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WsTicket is a single-use credential that chat_service redeems during the
+// WebSocket handshake. Only the SHA-256 of the ticket is stored. A zero
+// ConversationID opens a user-scoped socket. The ticket and the socket it
+// opens only last as long as the session identified by SessionID.
+type WsTicket struct {
+	gorm.Model
+	TicketHash     string     `gorm:"uniqueIndex;not null"`
+	ProfileID      uint       `gorm:"not null;index"`
+	ConversationID uint       `gorm:"not null;default:0"`
+	ExpiresAt      time.Time  `gorm:"not null;index"`
+	UsedAt         *time.Time `gorm:"default:null"`
+	SessionID      string     `gorm:"not null;default:''"`
+}
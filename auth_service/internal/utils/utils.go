@@ -1,8 +1,12 @@
 package utils
 
 import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
-	"math/rand"
+	"math/big"
 	"os"
 	"strings"
 	"time"
@@ -24,7 +28,10 @@ type JwtClaims struct {
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(email string, userID uint, expiryTime time.Duration) (string, error) {
+// GenerateToken signs an access token. sessionID becomes the jti claim; it is
+// empty for tokens that do not belong to a session, such as the one VerifyOtp
+// hands out for Login.
+func GenerateToken(email string, userID uint, sessionID string, expiryTime time.Duration) (string, error) {
 	secret_key := os.Getenv("JWT_TOKEN")
 
 	fmt.Printf("Generating JWT for user: %s with ID: %d\n", email, userID)
@@ -38,6 +45,7 @@ func GenerateToken(email string, userID uint, expiryTime time.Duration) (string,
 		Email:  email,
 		UserID: userID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiryTime)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -90,6 +98,7 @@ func DecodeJWT(tokenString string) (map[string]interface{}, error) {
 		claimsMap := map[string]interface{}{
 			"email":   claims.Email,
 			"user_id": claims.UserID,
+			"jti":     claims.ID,
 			"exp":     claims.ExpiresAt.Unix(),
 		}
 
@@ -100,8 +109,42 @@ func DecodeJWT(tokenString string) (map[string]interface{}, error) {
 	return nil, fmt.Errorf("invalid token claims")
 }
 
+// RandomNumberGenerate returns a six-digit number drawn from the system
+// CSPRNG. OTPs are generated with it, so it must stay unpredictable.
 func RandomNumberGenerate() uint {
-	return uint(rand.Intn(899999) + 100000)
+	n, err := crand.Int(crand.Reader, big.NewInt(900000))
+	if err != nil {
+		// crypto/rand does not fail on supported platforms.
+		panic(fmt.Sprintf("failed to read random number: %v", err))
+	}
+	return uint(n.Int64() + 100000)
+}
+
+// HashOtp hashes an OTP for storage. The email salts it so equal codes for
+// different addresses hash differently.
+func HashOtp(email string, otp uint) (string, error) {
+	return HashPassword(fmt.Sprintf("%d", otp), email)
+}
+
+func CompareOtp(hashedOtp, email string, otp uint) bool {
+	return ComparePassword(hashedOtp, fmt.Sprintf("%d", otp), email)
+}
+
+// GenerateOpaqueToken returns a URL-safe random token carrying n bytes of
+// entropy.
+func GenerateOpaqueToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := crand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken is how opaque tokens are looked up in the database, so a leaked
+// table never yields usable credentials.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 func HashPassword(password, salt string) (string, error) {
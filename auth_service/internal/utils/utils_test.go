@@ -0,0 +1,36 @@
+package utils
+
+import "testing"
+
+func TestRandomNumberGenerate(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		if n := RandomNumberGenerate(); n < 100000 || n > 999999 {
+			t.Fatalf("RandomNumberGenerate() = %d, want six digits", n)
+		}
+	}
+}
+
+func TestHashOtp(t *testing.T) {
+	hash, err := HashOtp("a@example.com", 123456)
+	if err != nil {
+		t.Fatalf("HashOtp() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		email string
+		otp   uint
+		want  bool
+	}{
+		{"same code and email", "a@example.com", 123456, true},
+		{"other code", "a@example.com", 123457, false},
+		{"other email", "b@example.com", 123456, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompareOtp(hash, tt.email, tt.otp); got != tt.want {
+				t.Errorf("CompareOtp() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
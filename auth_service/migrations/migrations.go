@@ -19,8 +19,29 @@ func main() {
 	if err := database.DB.AutoMigrate(&models.UserLogin{}); err != nil {
 		log.Printf("Error migrating UserLogin: %v", err)
 	}
+	// OTPs used to be stored in plaintext; none of them survive the move to
+	// hashed codes.
+	if err := database.DB.Exec("ALTER TABLE user_logins DROP COLUMN IF EXISTS otp").Error; err != nil {
+		log.Printf("Error dropping plaintext OTP column: %v", err)
+	}
+
+	if err := database.DB.AutoMigrate(&models.KnownDevice{}); err != nil {
+		log.Printf("Error migrating KnownDevice: %v", err)
+	}
 
 	if err := database.DB.AutoMigrate(&models.Profile{}); err != nil {
 		log.Printf("Error migrating Profile: %v", err)
 	}
+
+	if err := database.DB.AutoMigrate(&models.WsTicket{}); err != nil {
+		log.Printf("Error migrating WsTicket: %v", err)
+	}
+
+	if err := database.DB.AutoMigrate(&models.Session{}); err != nil {
+		log.Printf("Error migrating Session: %v", err)
+	}
+
+	if err := database.DB.AutoMigrate(&models.RefreshToken{}); err != nil {
+		log.Printf("Error migrating RefreshToken: %v", err)
+	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"chat_service/internal/broker"
 	"chat_service/internal/database"
 	"chat_service/internal/ws"
 	"flag"
@@ -28,7 +29,19 @@ func main() {
 
 	r := gin.Default()
 	flag.Parse()
-	hub := ws.NewHub()
+	b, err := newBroker()
+	if err != nil {
+		log.Fatal("Error starting broker: ", err)
+	}
+	defer b.Close()
+
+	events, err := newEventListener()
+	if err != nil {
+		log.Fatal("Error listening for service events: ", err)
+	}
+	defer events.Close()
+
+	hub := ws.NewHub(b, events.Messages())
 	go hub.Hubrun()
 
 	r.GET("/ws", func(c *gin.Context) {
@@ -38,3 +51,30 @@ func main() {
 	log.Printf("Server starting on port %s", port)
 	r.Run(":" + port)
 }
+
+// newBroker selects how the hub shares traffic with other replicas.
+// CHAT_BROKER=postgres uses LISTEN/NOTIFY on DATABASE_URL; anything else keeps
+// a single in-process hub.
+func newBroker() (broker.Broker, error) {
+	switch os.Getenv("CHAT_BROKER") {
+	case "postgres":
+		channel := os.Getenv("CHAT_BROKER_CHANNEL")
+		if channel == "" {
+			channel = "chat_events"
+		}
+		return broker.NewPostgres(database.DB, os.Getenv("DATABASE_URL"), channel)
+	default:
+		return broker.NewLocal(), nil
+	}
+}
+
+// newEventListener receives what other services, such as user_service's REST
+// handlers, publish about conversations. It listens whichever broker the hub
+// uses, on CHAT_EVENTS_CHANNEL.
+func newEventListener() (*broker.Listener, error) {
+	channel := os.Getenv("CHAT_EVENTS_CHANNEL")
+	if channel == "" {
+		channel = "chat_service_events"
+	}
+	return broker.NewListener(database.DB, os.Getenv("DATABASE_URL"), channel)
+}
@@ -2,7 +2,10 @@ module chat_service
 
 go 1.24.5
 
-require gorm.io/driver/postgres v1.6.0
+require (
+	golang.org/x/net v0.25.0
+	gorm.io/driver/postgres v1.6.0
+)
 
 require (
 	github.com/bytedance/sonic v1.11.6 // indirect
@@ -36,7 +39,6 @@ require (
 	github.com/ugorji/go/codec v1.2.12 // indirect
 	golang.org/x/arch v0.8.0 // indirect
 	golang.org/x/crypto v0.31.0 // indirect
-	golang.org/x/net v0.25.0 // indirect
 	golang.org/x/sync v0.16.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
@@ -0,0 +1,39 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Message is one unit of hub fan-out. It is delivered to every connection
+// subscribed to ConversationID or, when ProfileID is set, to every connection
+// of that profile.
+//
+// MemberAdded and MemberRemoved announce a membership change of
+// ConversationID. The new member's user-scoped connections are subscribed
+// before delivery; a removed member's connections are unsubscribed after it.
+//
+// PreviewMessage asks for link previews of a message another service
+// changed, which chat_service fetches as it does for its own edits.
+//
+// RevokedSessions lists sessions auth_service logged out; their connections
+// are closed and nothing is delivered.
+type Message struct {
+	ConversationID  uint            `json:"conversation_id,omitempty"`
+	ProfileID       uint            `json:"profile_id,omitempty"`
+	Data            json.RawMessage `json:"data"`
+	SenderID        uint            `json:"sender_id,omitempty"`
+	SenderConnID    string          `json:"sender_conn_id,omitempty"`
+	MemberAdded     uint            `json:"member_added,omitempty"`
+	MemberRemoved   uint            `json:"member_removed,omitempty"`
+	PreviewMessage  uint            `json:"preview_message,omitempty"`
+	RevokedSessions []string        `json:"revoked_sessions,omitempty"`
+}
+
+// Broker carries hub traffic between chat_service replicas. Every published
+// message is delivered on Messages of every replica, the publisher included.
+type Broker interface {
+	Publish(ctx context.Context, msg Message) error
+	Messages() <-chan Message
+	Close() error
+}
@@ -0,0 +1,145 @@
+package broker
+
+import (
+	"chat_service/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+const maxReconnectBackoff = 30 * time.Second
+
+type notification struct {
+	Message *Message `json:"m,omitempty"`
+	SpillID uint     `json:"spill_id,omitempty"`
+}
+
+// Listener receives the messages NOTIFYed on a channel, on a dedicated
+// connection that reconnects when lost. Messages notified while it is
+// reconnecting are not replayed to it.
+type Listener struct {
+	db       *gorm.DB
+	dsn      string
+	channel  string
+	messages chan Message
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewListener listens on channel over a connection opened from dsn. db loads
+// payloads that were too large to notify.
+func NewListener(db *gorm.DB, dsn, channel string) (*Listener, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := listen(ctx, dsn, channel)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	l := &Listener{
+		db:       db,
+		dsn:      dsn,
+		channel:  channel,
+		messages: make(chan Message, 256),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go l.run(ctx, conn)
+	return l, nil
+}
+
+func listen(ctx context.Context, dsn, channel string) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open listener connection: %w", err)
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		conn.Close(context.Background())
+		return nil, fmt.Errorf("failed to listen on %s: %w", channel, err)
+	}
+	return conn, nil
+}
+
+func (l *Listener) run(ctx context.Context, conn *pgx.Conn) {
+	defer close(l.done)
+	backoff := time.Second
+
+	for {
+		if conn == nil {
+			var err error
+			conn, err = listen(ctx, l.dsn, l.channel)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("broker: reconnect failed: %v", err)
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					return
+				}
+				backoff = min(backoff*2, maxReconnectBackoff)
+				continue
+			}
+			backoff = time.Second
+		}
+
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			conn.Close(context.Background())
+			conn = nil
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("broker: lost listener connection: %v", err)
+			continue
+		}
+
+		msg, err := l.decode(ctx, n.Payload)
+		if err != nil {
+			log.Printf("broker: dropping notification: %v", err)
+			continue
+		}
+		select {
+		case l.messages <- msg:
+		case <-ctx.Done():
+			conn.Close(context.Background())
+			return
+		}
+	}
+}
+
+func (l *Listener) decode(ctx context.Context, payload string) (Message, error) {
+	var n notification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		return Message{}, err
+	}
+	if n.Message != nil {
+		return *n.Message, nil
+	}
+
+	var spill models.BrokerPayload
+	if err := l.db.WithContext(ctx).First(&spill, n.SpillID).Error; err != nil {
+		return Message{}, fmt.Errorf("failed to load spilled payload %d: %w", n.SpillID, err)
+	}
+	var msg Message
+	if err := json.Unmarshal([]byte(spill.Payload), &msg); err != nil {
+		return Message{}, err
+	}
+	return msg, nil
+}
+
+func (l *Listener) Messages() <-chan Message {
+	return l.messages
+}
+
+func (l *Listener) Close() error {
+	l.cancel()
+	<-l.done
+	return nil
+}
@@ -0,0 +1,30 @@
+package broker
+
+import "context"
+
+// Local is the single-process broker: published messages go straight back
+// to this replica's hub.
+type Local struct {
+	messages chan Message
+}
+
+func NewLocal() *Local {
+	return &Local{messages: make(chan Message, 256)}
+}
+
+func (l *Local) Publish(ctx context.Context, msg Message) error {
+	select {
+	case l.messages <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Local) Messages() <-chan Message {
+	return l.messages
+}
+
+func (l *Local) Close() error {
+	return nil
+}
@@ -0,0 +1,67 @@
+package broker
+
+import (
+	"chat_service/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxNotifyPayload keeps notifications under Postgres' 8000 byte limit.
+	// Larger messages are spilled to models.BrokerPayload.
+	maxNotifyPayload = 7900
+
+	spillRetention = time.Minute
+)
+
+// Postgres shares hub traffic between replicas through LISTEN/NOTIFY on the
+// database chat_service already uses. Messages published while a replica's
+// listener is reconnecting are not replayed to it.
+type Postgres struct {
+	*Listener
+	db      *gorm.DB
+	channel string
+}
+
+// NewPostgres publishes through db and listens on a dedicated connection
+// opened from dsn.
+func NewPostgres(db *gorm.DB, dsn, channel string) (*Postgres, error) {
+	listener, err := NewListener(db, dsn, channel)
+	if err != nil {
+		return nil, err
+	}
+	return &Postgres{Listener: listener, db: db, channel: channel}, nil
+}
+
+func (p *Postgres) Publish(ctx context.Context, msg Message) error {
+	payload, err := json.Marshal(notification{Message: &msg})
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > maxNotifyPayload {
+		raw, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		spill := models.BrokerPayload{Payload: string(raw)}
+		if err := p.db.WithContext(ctx).Create(&spill).Error; err != nil {
+			return fmt.Errorf("failed to spill payload: %w", err)
+		}
+		if err := p.db.WithContext(ctx).Unscoped().
+			Where("created_at < ?", time.Now().Add(-spillRetention)).
+			Delete(&models.BrokerPayload{}).Error; err != nil {
+			log.Printf("broker: failed to prune spilled payloads: %v", err)
+		}
+		if payload, err = json.Marshal(notification{SpillID: spill.ID}); err != nil {
+			return err
+		}
+	}
+
+	return p.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", p.channel, string(payload)).Error
+}
@@ -0,0 +1,269 @@
+// Package linkpreview fetches the title, description and image a web page
+// advertises about itself, without letting a chat message reach internal
+// services.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	defaultTimeout      = 5 * time.Second
+	defaultMaxBytes     = 512 * 1024
+	defaultMaxRedirects = 3
+
+	maxTitleLength       = 200
+	maxDescriptionLength = 500
+	maxURLLength         = 2048
+)
+
+var (
+	ErrUnsupportedURL = errors.New("only absolute http and https URLs can be previewed")
+	ErrNotHTML        = errors.New("response is not an HTML page")
+	ErrNoMetadata     = errors.New("page has no title or description")
+)
+
+// Preview is what a page says about itself through Open Graph tags, falling
+// back to its <title> and description meta tag.
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+type Config struct {
+	// Timeout bounds the whole fetch, redirects included.
+	Timeout time.Duration
+	// MaxBytes is how much of the page is read; metadata past it is ignored.
+	MaxBytes     int64
+	MaxRedirects int
+	// AllowPrivateNetworks disables the address guard. Only tests against a
+	// local httptest server should set it.
+	AllowPrivateNetworks bool
+}
+
+type Fetcher struct {
+	client   *http.Client
+	timeout  time.Duration
+	maxBytes int64
+}
+
+func New(cfg Config) *Fetcher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultMaxBytes
+	}
+	if cfg.MaxRedirects <= 0 {
+		cfg.MaxRedirects = defaultMaxRedirects
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = dialGuard
+	}
+
+	transport := &http.Transport{
+		// No proxy: a proxy would make the connection on our behalf and
+		// bypass the dial guard.
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          16,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	maxRedirects := cfg.MaxRedirects
+	return &Fetcher{
+		client: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				return checkURL(req.URL)
+			},
+		},
+		timeout:  cfg.Timeout,
+		maxBytes: cfg.MaxBytes,
+	}
+}
+
+func checkURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return ErrUnsupportedURL
+	}
+	return nil
+}
+
+// Fetch downloads rawURL and extracts its preview.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Preview, error) {
+	if len(rawURL) > maxURLLength {
+		return Preview{}, ErrUnsupportedURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Preview{}, ErrUnsupportedURL
+	}
+	if err := checkURL(u); err != nil {
+		return Preview{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	req.Header.Set("User-Agent", "ztaChat-LinkPreview/1.0")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Preview{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Preview{}, ErrNotHTML
+	}
+
+	preview := parse(io.LimitReader(resp.Body, f.maxBytes), resp.Request.URL)
+	preview.URL = rawURL
+	if preview.Title == "" && preview.Description == "" {
+		return Preview{}, ErrNoMetadata
+	}
+	return preview, nil
+}
+
+// parse reads the document head. Open Graph values win over the plain
+// <title> and description.
+func parse(r io.Reader, base *url.URL) Preview {
+	var preview Preview
+	var title, description string
+
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return finish(preview, title, description, base)
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = string(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return finish(preview, title, description, base)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = true
+			case "body":
+				return finish(preview, title, description, base)
+			case "meta":
+				if !hasAttr {
+					continue
+				}
+				key, content := metaAttributes(tokenizer)
+				switch key {
+				case "og:title", "twitter:title":
+					if preview.Title == "" {
+						preview.Title = content
+					}
+				case "og:description", "twitter:description":
+					if preview.Description == "" {
+						preview.Description = content
+					}
+				case "og:image", "og:image:url", "twitter:image":
+					if preview.ImageURL == "" {
+						preview.ImageURL = content
+					}
+				case "og:site_name":
+					preview.SiteName = content
+				case "description":
+					description = content
+				}
+			}
+		}
+	}
+}
+
+func metaAttributes(tokenizer *html.Tokenizer) (key, content string) {
+	for {
+		name, value, more := tokenizer.TagAttr()
+		switch string(name) {
+		case "property", "name":
+			if key == "" {
+				key = strings.ToLower(strings.TrimSpace(string(value)))
+			}
+		case "content":
+			content = string(value)
+		}
+		if !more {
+			return key, content
+		}
+	}
+}
+
+func finish(preview Preview, title, description string, base *url.URL) Preview {
+	if preview.Title == "" {
+		preview.Title = title
+	}
+	if preview.Description == "" {
+		preview.Description = description
+	}
+	preview.Title = clean(preview.Title, maxTitleLength)
+	preview.Description = clean(preview.Description, maxDescriptionLength)
+	preview.SiteName = clean(preview.SiteName, maxTitleLength)
+	preview.ImageURL = resolveImage(preview.ImageURL, base)
+	return preview
+}
+
+// clean collapses whitespace and cuts s to limit runes.
+func clean(s string, limit int) string {
+	s = strings.Join(strings.Fields(strings.ToValidUTF8(s, "")), " ")
+	if utf8.RuneCountInString(s) > limit {
+		s = string([]rune(s)[:limit-1]) + "…"
+	}
+	return s
+}
+
+func resolveImage(ref string, base *url.URL) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || base == nil {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || checkURL(u) != nil || len(u.String()) > maxURLLength {
+		return ""
+	}
+	return u.String()
+}
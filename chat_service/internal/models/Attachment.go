@@ -0,0 +1,16 @@
+package models
+
+import "gorm.io/gorm"
+
+// Attachment is an uploaded file that messages of ConversationID can
+// reference. Uploads are handled by user_service.
+type Attachment struct {
+	gorm.Model
+	ConversationID uint        `gorm:"not null;index"`
+	UploaderID     uint        `gorm:"not null;index"`
+	ContentType    ContentType `gorm:"not null"`
+	MimeType       string      `gorm:"not null"`
+	FileName       string      `gorm:"not null"`
+	Size           int64       `gorm:"not null"`
+	StorageKey     string      `gorm:"not null;index"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "gorm.io/gorm"
+
+// BrokerPayload holds chat_service broker messages too large for a Postgres
+// NOTIFY payload; the notification then only carries the row id.
+type BrokerPayload struct {
+	gorm.Model
+	Payload string `gorm:"type:text;not null"`
+}
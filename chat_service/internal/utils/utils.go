@@ -11,3 +11,14 @@ type CurrentUserProfile struct {
 	Username string `form:"username" binding:"omitempty"`
 	ID       uint   `form:"id" binding:"omitempty"`
 }
+
+type JwtClaims struct {
+	Email     string `json:"email"`
+	UserID    uint   `json:"user_id"`
+	SessionID string `json:"jti"`
+}
+
+type VerifyResp struct {
+	Response
+	User JwtClaims `json:"user"`
+}
@@ -0,0 +1,156 @@
+package ws
+
+import (
+	"chat_service/internal/database"
+	"chat_service/internal/models"
+	"chat_service/internal/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// bearerSubprotocol lets browsers, which cannot set headers on a WebSocket
+// upgrade, pass the JWT as `new WebSocket(url, ["bearer", token])`.
+const bearerSubprotocol = "bearer"
+
+var (
+	errMissingToken   = errors.New("missing bearer token")
+	errInvalidToken   = errors.New("invalid bearer token")
+	errNotParticipant = errors.New("profile is not a participant of this conversation")
+)
+
+var verifyClient = &http.Client{Timeout: 5 * time.Second}
+
+func bearerToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		return authHeader[7:]
+	}
+
+	protocols := websocketSubprotocols(r)
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == bearerSubprotocol {
+			return protocols[i+1]
+		}
+	}
+	return ""
+}
+
+func websocketSubprotocols(r *http.Request) []string {
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(header, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				protocols = append(protocols, p)
+			}
+		}
+	}
+	return protocols
+}
+
+// verifyToken asks auth_service to validate the JWT, the same way user_service
+// does, so chat_service never needs the signing secret.
+func verifyToken(token string) (utils.JwtClaims, error) {
+	verifyUrl := os.Getenv("VERIFY_TOKEN_CLAIMS")
+	if verifyUrl == "" {
+		return utils.JwtClaims{}, fmt.Errorf("VERIFY_TOKEN_CLAIMS is not set in the environment variables")
+	}
+
+	req, err := http.NewRequest("GET", verifyUrl, nil)
+	if err != nil {
+		return utils.JwtClaims{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := verifyClient.Do(req)
+	if err != nil {
+		return utils.JwtClaims{}, fmt.Errorf("failed to reach auth service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusBadRequest {
+		return utils.JwtClaims{}, errInvalidToken
+	}
+
+	var verifyResp utils.VerifyResp
+	if err := json.NewDecoder(resp.Body).Decode(&verifyResp); err != nil {
+		return utils.JwtClaims{}, fmt.Errorf("failed to decode auth service response: %w", err)
+	}
+	if !verifyResp.Success || verifyResp.User.Email == "" {
+		return utils.JwtClaims{}, errInvalidToken
+	}
+
+	return verifyResp.User, nil
+}
+
+// authenticate resolves the profile and session behind the bearer token of
+// the upgrade request.
+func authenticate(r *http.Request) (models.Profile, string, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return models.Profile{}, "", errMissingToken
+	}
+
+	claims, err := verifyToken(token)
+	if err != nil {
+		return models.Profile{}, "", err
+	}
+
+	var profile models.Profile
+	if err := database.DB.Where("email = ?", claims.Email).First(&profile).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Profile{}, "", errInvalidToken
+		}
+		return models.Profile{}, "", err
+	}
+	return profile, claims.SessionID, nil
+}
+
+// participantCondition matches conversations the profile is a member of.
+// One-to-one conversations have a member row for each side, so this covers
+// both kinds.
+const participantCondition = "EXISTS (SELECT 1 FROM conversation_members " +
+	"WHERE conversation_members.conversation_id = conversations.id " +
+	"AND conversation_members.user_id = ? AND conversation_members.deleted_at IS NULL)"
+
+func isParticipant(conversationID, profileID uint) (bool, error) {
+	return isParticipantIn(database.DB, conversationID, profileID)
+}
+
+// isParticipantIn is isParticipant inside a transaction.
+func isParticipantIn(tx *gorm.DB, conversationID, profileID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.Conversations{}).
+		Where("id = ?", conversationID).
+		Where(participantCondition, profileID).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func participantConversations(profileID uint) ([]uint, error) {
+	var conversationIDs []uint
+	err := database.DB.Model(&models.Conversations{}).
+		Where(participantCondition, profileID).
+		Pluck("id", &conversationIDs).Error
+	return conversationIDs, err
+}
+
+func authStatus(err error) int {
+	switch {
+	case errors.Is(err, errMissingToken), errors.Is(err, errInvalidToken):
+		return http.StatusUnauthorized
+	case errors.Is(err, errNotParticipant):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
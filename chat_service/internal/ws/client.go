@@ -1,13 +1,18 @@
 package ws
 
 import (
-	"bytes"
+	"chat_service/internal/broker"
 	"chat_service/internal/database"
 	"chat_service/internal/models"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
 )
 
 const (
@@ -17,33 +22,105 @@ const (
 
 	pingPeriod = (pongWait * 9) / 10
 
-	maxMessageSize = 512
-)
+	maxMessageSize = 16 * 1024
 
-var (
-	newline = []byte{'\n'}
-	space   = []byte{' '}
+	replayBatchSize = 100
 )
 
 type Client struct {
+	// id identifies this connection, so one profile can hold several.
+	id string
+
 	hub *Hub
 
 	conn *websocket.Conn
 
 	send chan []byte
 
-	conversationID uint
-
 	profileID uint
+
+	// sessionID is the auth_service session the connection was opened with.
+	// Logging that session out closes the connection.
+	sessionID string
+
+	// subscriptions is checked by readPump before acting on a frame. The hub
+	// keeps its own index and changes this one too when membership changes,
+	// hence the lock.
+	subscriptionsMu sync.Mutex
+	subscriptions   map[uint]bool
+
+	// userScoped connections follow every conversation of the profile,
+	// including ones it joins while connected.
+	userScoped bool
+
+	// conversationIDs are the conversations the connection starts out
+	// subscribed to.
+	conversationIDs []uint
+
+	// replayAfter is the last message id the client saw before reconnecting.
+	// writePump streams newer messages of conversationIDs before any live
+	// traffic.
+	replayAfter uint
+
+	typing typingTracker
 }
 
-type BroadcastMessage struct {
-	ConversationID uint
-	Data           []byte
-	SenderID       uint
+type BroadcastMessage = broker.Message
+
+func (c *Client) isSubscribed(conversationID uint) bool {
+	c.subscriptionsMu.Lock()
+	defer c.subscriptionsMu.Unlock()
+	return c.subscriptions[conversationID]
+}
+
+func (c *Client) setSubscribed(conversationID uint, subscribed bool) {
+	c.subscriptionsMu.Lock()
+	defer c.subscriptionsMu.Unlock()
+	if subscribed {
+		c.subscriptions[conversationID] = true
+	} else {
+		delete(c.subscriptions, conversationID)
+	}
+}
+
+func newConnID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("failed to generate connection id: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+var (
+	errInvalidParent     = errors.New("parent message is not part of this conversation")
+	errInvalidAttachment = errors.New("attachment does not belong to this conversation or sender, or does not match the content type")
+)
+
+// checkAttachments verifies that every attachment referenced by parts was
+// uploaded by profileID to the conversation, with the part's content type.
+func checkAttachments(tx *gorm.DB, parts []ContentPart, conversationID, profileID uint) error {
+	for _, part := range parts {
+		if part.AttachmentID == 0 {
+			continue
+		}
+		var count int64
+		if err := tx.Model(&models.Attachment{}).
+			Where("id = ? AND conversation_id = ? AND uploader_id = ? AND content_type = ?",
+				part.AttachmentID, conversationID, profileID, part.ContentType).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errInvalidAttachment
+		}
+	}
+	return nil
 }
 
-func saveMessageToDB(conversationID uint, content string, profileID uint) error {
+// saveMessageToDB stores a message unless the sender already stored one with
+// the same client_msg_id in the conversation, in which case that row is
+// returned with duplicate set. A reply is attached to the root of its parent's thread.
+func saveMessageToDB(env Envelope, profileID uint) (message models.Messages, duplicate bool, err error) {
 	tx := database.DB.Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -51,26 +128,60 @@ func saveMessageToDB(conversationID uint, content string, profileID uint) error
 		}
 	}()
 
-	message := models.Messages{
-		ConversationID: conversationID,
+	err = tx.Where("conversation_id = ? AND sender_id = ? AND client_msg_id = ?", env.ConversationID, profileID, env.ClientMsgID).
+		First(&message).Error
+	if err == nil {
+		tx.Rollback()
+		return message, true, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		tx.Rollback()
+		return message, false, err
+	}
+
+	var parentID *uint
+	if env.ParentID != 0 {
+		var parent models.Messages
+		if err := tx.Select("id", "parent_id").
+			Where("id = ? AND conversation_id = ?", env.ParentID, env.ConversationID).
+			First(&parent).Error; err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return message, false, errInvalidParent
+			}
+			return message, false, err
+		}
+		rootID := parent.ID
+		if parent.ParentID != nil {
+			rootID = *parent.ParentID
+		}
+		parentID = &rootID
+	}
+
+	if err := checkAttachments(tx, env.Content, env.ConversationID, profileID); err != nil {
+		tx.Rollback()
+		return message, false, err
+	}
+
+	clientMsgID := env.ClientMsgID
+	message = models.Messages{
+		ConversationID: env.ConversationID,
 		SenderID:       profileID,
-		Content: []models.MessageContent{
-			{
-				ContentType: models.ContentTypeText,
-				Content:     content,
-			},
-		},
+		ClientMsgID:    &clientMsgID,
+		ParentID:       parentID,
+		Content:        contentFromParts(env.Content),
 	}
 
 	if err := tx.Create(&message).Error; err != nil {
 		tx.Rollback()
-		return err
+		return message, false, err
 	}
-	return tx.Commit().Error
+	return message, false, tx.Commit().Error
 }
 
 func (c *Client) readPump() {
 	defer func() {
+		c.stopAllTyping()
 		c.hub.unregister <- c
 		c.conn.Close()
 	}()
@@ -78,31 +189,192 @@ func (c *Client) readPump() {
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error { c.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
 	for {
-		_, message, err := c.conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("error: %v", err)
 			}
 			break
 		}
-		message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
-		if err := saveMessageToDB(c.conversationID, string(message), c.profileID); err != nil {
-			log.Printf("failed to save message: %v", err)
+
+		env, frameErr := decodeEnvelope(data)
+		if frameErr != nil {
+			c.replyFrame(errorFrame(env.ClientMsgID, frameErr))
+			continue
+		}
+
+		switch env.Type {
+		case FrameMessage:
+			c.handleMessage(env)
+		case FrameSubscribe:
+			c.handleSubscribe(env)
+		case FrameUnsubscribe:
+			c.handleUnsubscribe(env)
+		case FrameTypingStart, FrameTypingStop:
+			c.handleTyping(env)
+		case FrameReceipt:
+			c.handleReceipt(env)
+		case FrameEdit, FrameDelete:
+			c.handleChange(env)
+		case FrameReactionAdd, FrameReactionRemove:
+			c.handleReaction(env)
+		}
+	}
+}
+
+func (c *Client) replyFrame(data []byte) {
+	c.hub.reply <- clientFrame{client: c, data: data}
+}
+
+func (c *Client) handleMessage(env Envelope) {
+	if !c.isSubscribed(env.ConversationID) {
+		c.replyFrame(errorFrame(env.ClientMsgID, &ErrorPayload{
+			Code:    ErrInvalidConversation,
+			Message: "not subscribed to this conversation",
+		}))
+		return
+	}
+
+	message, duplicate, err := saveMessageToDB(env, c.profileID)
+	if errors.Is(err, errInvalidParent) {
+		c.replyFrame(errorFrame(env.ClientMsgID, &ErrorPayload{Code: ErrInvalidParent, Message: err.Error()}))
+		return
+	}
+	if errors.Is(err, errInvalidAttachment) {
+		c.replyFrame(errorFrame(env.ClientMsgID, &ErrorPayload{Code: ErrInvalidContent, Message: err.Error()}))
+		return
+	}
+	if err != nil {
+		log.Printf("failed to save message: %v", err)
+		c.replyFrame(nackFrame(env.ClientMsgID, env.ConversationID, &ErrorPayload{
+			Code:    ErrInternal,
+			Message: "failed to persist message",
+		}))
+		return
+	}
+
+	c.replyFrame(ackFrame(env.ClientMsgID, message))
+	if duplicate {
+		// A retry of a message that was already stored and broadcast.
+		return
+	}
+
+	broadcast := messageEnvelope(message)
+	broadcast.SentAt = env.SentAt
+
+	c.hub.publish(BroadcastMessage{
+		ConversationID: env.ConversationID,
+		Data:           encodeEnvelope(broadcast),
+		SenderID:       c.profileID,
+		SenderConnID:   c.id,
+	})
+	if len(previewURLs(message.Content)) > 0 {
+		go c.hub.attachPreviews(message.ID)
+	}
+}
+
+func (c *Client) handleSubscribe(env Envelope) {
+	if !c.isSubscribed(env.ConversationID) {
+		ok, err := isParticipant(env.ConversationID, c.profileID)
+		if err != nil {
+			log.Printf("failed to check participant: %v", err)
+			c.replyFrame(errorFrame(env.ClientMsgID, &ErrorPayload{Code: ErrInternal, Message: "failed to subscribe"}))
+			return
+		}
+		if !ok {
+			c.replyFrame(errorFrame(env.ClientMsgID, &ErrorPayload{
+				Code:    ErrInvalidConversation,
+				Message: "not a participant of this conversation",
+			}))
+			return
+		}
+		c.setSubscribed(env.ConversationID, true)
+		c.hub.subscribe <- subscription{client: c, conversationID: env.ConversationID}
+	}
+	c.replyFrame(controlFrame(FrameSubscribed, env.ConversationID))
+}
+
+func (c *Client) handleUnsubscribe(env Envelope) {
+	if c.isSubscribed(env.ConversationID) {
+		c.setSubscribed(env.ConversationID, false)
+		c.stopTyping(env.ConversationID)
+		c.hub.unsubscribe <- subscription{client: c, conversationID: env.ConversationID}
+	}
+	c.replyFrame(controlFrame(FrameUnsubscribed, env.ConversationID))
+}
+
+// replay writes every message newer than after straight to the connection
+// and returns the id of the last one written.
+func (c *Client) replay(after uint) (uint, error) {
+	for {
+		var messages []models.Messages
+		if err := database.DB.Preload("Content.LinkPreview").
+			Where("conversation_id IN ? AND id > ?", c.conversationIDs, after).
+			Order("id ASC").
+			Limit(replayBatchSize).
+			Find(&messages).Error; err != nil {
+			return after, err
+		}
+
+		for _, message := range messages {
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, encodeEnvelope(messageEnvelope(message))); err != nil {
+				return after, err
+			}
+			after = message.ID
 		}
-		c.hub.broadcast <- BroadcastMessage{
-			ConversationID: c.conversationID,
-			Data:           message,
-			SenderID:       c.profileID,
+		if len(messages) < replayBatchSize {
+			return after, nil
 		}
 	}
 }
 
+// start registers the connection with the hub and starts reading from it. A
+// reconnecting client is replayed first and only then registered: live
+// traffic arriving during a long replay would otherwise fill send and get
+// the client evicted. A second replay after registering covers messages
+// stored in between; live frames queued meanwhile may repeat some of them,
+// and clients dedupe by message_id.
+func (c *Client) start() error {
+	replaying := c.replayAfter != 0 && len(c.conversationIDs) > 0
+	after := c.replayAfter
+	if replaying {
+		var err error
+		if after, err = c.replay(after); err != nil {
+			return err
+		}
+	}
+
+	c.hub.register <- registration{client: c, conversationIDs: c.conversationIDs}
+	go c.readPump()
+
+	if !replaying {
+		return nil
+	}
+	after, err := c.replay(after)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	c.conn.SetWriteDeadline(now.Add(writeWait))
+	return c.conn.WriteMessage(websocket.TextMessage, encodeEnvelope(Envelope{
+		Type:       FrameReplayDone,
+		MessageID:  after,
+		ServerTime: &now,
+	}))
+}
+
 func (c *Client) writePump() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
 	}()
+
+	if err := c.start(); err != nil {
+		log.Printf("failed to replay messages for profile %d: %v", c.profileID, err)
+		return
+	}
 	for {
 		select {
 		case message, ok := <-c.send:
@@ -113,19 +385,9 @@ func (c *Client) writePump() {
 				return
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write(newline)
-				w.Write(<-c.send)
-			}
-
-			if err := w.Close(); err != nil {
+			// Every envelope goes out as its own frame so clients can parse
+			// each one as a complete JSON document.
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				return
 			}
 		case <-ticker.C:
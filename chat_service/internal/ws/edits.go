@@ -0,0 +1,210 @@
+package ws
+
+import (
+	"chat_service/internal/database"
+	"chat_service/internal/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errMessageNotFound = errors.New("message not found")
+	errNotSender       = errors.New("only the sender can change a message")
+	errRetracted       = errors.New("message was deleted")
+	errSystemMessage   = errors.New("system messages cannot be changed")
+)
+
+// loadOwnMessage fetches and locks a live message of the conversation and
+// checks that profileID sent it and is still a participant. The lock keeps
+// concurrent edits and deletes, from here or user_service, in order.
+func loadOwnMessage(tx *gorm.DB, messageID, conversationID, profileID uint) (models.Messages, error) {
+	var message models.Messages
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Content").
+		Where("id = ? AND conversation_id = ?", messageID, conversationID).
+		First(&message).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return message, errMessageNotFound
+		}
+		return message, err
+	}
+	if message.IsSystem {
+		return message, errSystemMessage
+	}
+	if message.SenderID != profileID {
+		return message, errNotSender
+	}
+	if message.RetractedAt != nil {
+		return message, errRetracted
+	}
+
+	participant, err := isParticipantIn(tx, conversationID, profileID)
+	if err != nil {
+		return message, err
+	}
+	if !participant {
+		return message, errNotParticipant
+	}
+	return message, nil
+}
+
+// editMessageInDB replaces the content of a message, keeping the previous
+// content as a models.MessageEdit and the previews of links that stay.
+func editMessageInDB(messageID, conversationID, profileID uint, parts []ContentPart) (models.Messages, error) {
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	message, err := loadOwnMessage(tx, messageID, conversationID, profileID)
+	if err != nil {
+		tx.Rollback()
+		return message, err
+	}
+
+	if err := checkAttachments(tx, parts, conversationID, profileID); err != nil {
+		tx.Rollback()
+		return message, err
+	}
+
+	previous, err := json.Marshal(partsFromContent(message.Content))
+	if err != nil {
+		tx.Rollback()
+		return message, err
+	}
+	if err := tx.Create(&models.MessageEdit{
+		MessageID:       message.ID,
+		EditorID:        profileID,
+		PreviousContent: string(previous),
+	}).Error; err != nil {
+		tx.Rollback()
+		return message, err
+	}
+
+	if err := tx.Unscoped().Where("message_id = ?", message.ID).Delete(&models.MessageContent{}).Error; err != nil {
+		tx.Rollback()
+		return message, err
+	}
+	content := contentFromParts(parts)
+	for i := range content {
+		content[i].MessageID = message.ID
+	}
+	keepLinkPreviews(message.Content, content)
+	if err := tx.Create(&content).Error; err != nil {
+		tx.Rollback()
+		return message, err
+	}
+	if err := tx.Preload("LinkPreview").Where("message_id = ?", message.ID).Order("id").Find(&content).Error; err != nil {
+		tx.Rollback()
+		return message, err
+	}
+
+	now := time.Now()
+	if err := tx.Model(&message).Update("edited_at", now).Error; err != nil {
+		tx.Rollback()
+		return message, err
+	}
+	message.Content = content
+	message.EditedAt = &now
+
+	return message, tx.Commit().Error
+}
+
+// retractMessageInDB turns a message into a tombstone. Its content, edit
+// history and reactions are removed for good.
+func retractMessageInDB(messageID, conversationID, profileID uint) (models.Messages, error) {
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	message, err := loadOwnMessage(tx, messageID, conversationID, profileID)
+	if err != nil {
+		tx.Rollback()
+		return message, err
+	}
+
+	if err := tx.Unscoped().Where("message_id = ?", message.ID).Delete(&models.MessageContent{}).Error; err != nil {
+		tx.Rollback()
+		return message, err
+	}
+	if err := tx.Unscoped().Where("message_id = ?", message.ID).Delete(&models.MessageEdit{}).Error; err != nil {
+		tx.Rollback()
+		return message, err
+	}
+	if err := tx.Unscoped().Where("message_id = ?", message.ID).Delete(&models.MessageReaction{}).Error; err != nil {
+		tx.Rollback()
+		return message, err
+	}
+
+	now := time.Now()
+	if err := tx.Model(&message).Update("retracted_at", now).Error; err != nil {
+		tx.Rollback()
+		return message, err
+	}
+	message.Content = nil
+	message.RetractedAt = &now
+
+	return message, tx.Commit().Error
+}
+
+func changeErrorPayload(err error) *ErrorPayload {
+	switch {
+	case errors.Is(err, errMessageNotFound):
+		return &ErrorPayload{Code: ErrNotFound, Message: err.Error()}
+	case errors.Is(err, errNotSender), errors.Is(err, errRetracted), errors.Is(err, errSystemMessage),
+		errors.Is(err, errNotParticipant):
+		return &ErrorPayload{Code: ErrForbidden, Message: err.Error()}
+	case errors.Is(err, errInvalidAttachment):
+		return &ErrorPayload{Code: ErrInvalidContent, Message: err.Error()}
+	default:
+		log.Printf("failed to change message: %v", err)
+		return &ErrorPayload{Code: ErrInternal, Message: "failed to change message"}
+	}
+}
+
+// handleChange applies an edit or delete frame. The resulting frame goes to
+// every connection of the conversation, the requesting one included, which
+// doubles as its confirmation.
+func (c *Client) handleChange(env Envelope) {
+	if !c.isSubscribed(env.ConversationID) {
+		c.replyFrame(errorFrame(env.ClientMsgID, &ErrorPayload{
+			Code:    ErrInvalidConversation,
+			Message: "not subscribed to this conversation",
+		}))
+		return
+	}
+
+	var message models.Messages
+	var err error
+	if env.Type == FrameEdit {
+		message, err = editMessageInDB(env.MessageID, env.ConversationID, c.profileID, env.Content)
+	} else {
+		message, err = retractMessageInDB(env.MessageID, env.ConversationID, c.profileID)
+	}
+	if err != nil {
+		c.replyFrame(errorFrame(env.ClientMsgID, changeErrorPayload(err)))
+		return
+	}
+
+	changed := messageEnvelope(message)
+	changed.Type = env.Type
+	changed.ClientMsgID = env.ClientMsgID
+	c.hub.publish(BroadcastMessage{
+		ConversationID: env.ConversationID,
+		Data:           encodeEnvelope(changed),
+		SenderID:       c.profileID,
+	})
+	if env.Type == FrameEdit && len(previewURLs(message.Content)) > 0 {
+		go c.hub.attachPreviews(message.ID)
+	}
+}
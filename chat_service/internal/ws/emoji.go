@@ -0,0 +1,75 @@
+package ws
+
+import (
+	"strings"
+	"unicode"
+)
+
+// emojiBase holds the code points that are emoji on their own: the
+// pictographs of the Supplementary Multilingual Plane plus the older symbols
+// that render as emoji, such as ☀ and ❤.
+var emojiBase = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x00a9, Hi: 0x00ae, Stride: 5},
+		{Lo: 0x203c, Hi: 0x2049, Stride: 13},
+		{Lo: 0x2122, Hi: 0x2139, Stride: 23},
+		{Lo: 0x2194, Hi: 0x2199, Stride: 1},
+		{Lo: 0x21a9, Hi: 0x21aa, Stride: 1},
+		{Lo: 0x231a, Hi: 0x231b, Stride: 1},
+		{Lo: 0x2328, Hi: 0x2328, Stride: 1},
+		{Lo: 0x23cf, Hi: 0x23cf, Stride: 1},
+		{Lo: 0x23e9, Hi: 0x23f3, Stride: 1},
+		{Lo: 0x23f8, Hi: 0x23fa, Stride: 1},
+		{Lo: 0x24c2, Hi: 0x24c2, Stride: 1},
+		{Lo: 0x25aa, Hi: 0x25ab, Stride: 1},
+		{Lo: 0x25b6, Hi: 0x25c0, Stride: 10},
+		{Lo: 0x25fb, Hi: 0x25fe, Stride: 1},
+		{Lo: 0x2600, Hi: 0x27bf, Stride: 1},
+		{Lo: 0x2934, Hi: 0x2935, Stride: 1},
+		{Lo: 0x2b05, Hi: 0x2b07, Stride: 1},
+		{Lo: 0x2b1b, Hi: 0x2b1c, Stride: 1},
+		{Lo: 0x2b50, Hi: 0x2b55, Stride: 5},
+		{Lo: 0x3030, Hi: 0x303d, Stride: 13},
+		{Lo: 0x3297, Hi: 0x3299, Stride: 2},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f000, Hi: 0x1faff, Stride: 1},
+	},
+	LatinOffset: 1,
+}
+
+// emojiComponent holds the code points that only appear inside an emoji
+// sequence: joiners, variation selectors, skin tones, the keycap mark and the
+// tags of subdivision flags.
+var emojiComponent = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x200d, Hi: 0x200d, Stride: 1},
+		{Lo: 0x20e3, Hi: 0x20e3, Stride: 1},
+		{Lo: 0xfe0f, Hi: 0xfe0f, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0xe0020, Hi: 0xe007f, Stride: 1},
+	},
+}
+
+const keycapMark = '\u20e3'
+
+// isEmoji reports whether s is made only of emoji: pictographs, flags and
+// keycaps, with the components that join and modify them. Skin tones and
+// regional indicators sit in the pictograph range.
+func isEmoji(s string) bool {
+	keycap := strings.ContainsRune(s, keycapMark)
+	hasBase := false
+	for _, r := range s {
+		switch {
+		case unicode.Is(emojiBase, r):
+			hasBase = true
+		case keycap && (r == '#' || r == '*' || ('0' <= r && r <= '9')):
+			hasBase = true
+		case unicode.Is(emojiComponent, r):
+		default:
+			return false
+		}
+	}
+	return hasBase
+}
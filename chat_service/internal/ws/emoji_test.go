@@ -0,0 +1,36 @@
+package ws
+
+import "testing"
+
+func TestIsEmoji(t *testing.T) {
+	tests := []struct {
+		emoji string
+		want  bool
+	}{
+		{"👍", true},
+		{"❤️", true},
+		{"☀", true},
+		{"👍🏽", true},
+		{"👩‍💻", true},
+		{"👨‍👩‍👧", true},
+		{"🇪🇸", true},
+		{"🏴󠁧󠁢󠁳󠁣󠁴󠁿", true},
+		{"1️⃣", true},
+		{"#⃣", true},
+		{"🎉🎉", true},
+		{"", false},
+		{"lol", false},
+		{"1", false},
+		{"#", false},
+		{"👍 ", false},
+		{"👍x", false},
+		{"‍", false},
+		{"️", false},
+		{"<b>", false},
+	}
+	for _, tt := range tests {
+		if got := isEmoji(tt.emoji); got != tt.want {
+			t.Errorf("isEmoji(%q) = %v, want %v", tt.emoji, got, tt.want)
+		}
+	}
+}
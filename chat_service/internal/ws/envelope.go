@@ -0,0 +1,351 @@
+package ws
+
+import (
+	"chat_service/internal/models"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// protocolVersion is the envelope version this server speaks. Frames carrying
+// any other version are rejected with ErrUnsupportedVersion.
+const protocolVersion = 1
+
+const (
+	maxClientMsgIDLength = 64
+	maxContentParts      = 10
+	maxContentLength     = 4000
+	maxEmojiLength       = 32
+)
+
+type FrameType string
+
+const (
+	FrameMessage FrameType = "message"
+	FrameAck     FrameType = "ack"
+	FrameNack    FrameType = "nack"
+	FrameError   FrameType = "error"
+
+	// Control frames. A client sends subscribe/unsubscribe and the server
+	// confirms with subscribed/unsubscribed.
+	FrameSubscribe    FrameType = "subscribe"
+	FrameUnsubscribe  FrameType = "unsubscribe"
+	FrameSubscribed   FrameType = "subscribed"
+	FrameUnsubscribed FrameType = "unsubscribed"
+
+	// FrameReplayDone follows the missed messages streamed after a reconnect;
+	// its message_id is the newest one replayed.
+	FrameReplayDone FrameType = "replay_done"
+
+	// Typing indicators are relayed to the other participants and never
+	// stored.
+	FrameTypingStart FrameType = "typing_start"
+	FrameTypingStop  FrameType = "typing_stop"
+
+	// FrameReceipt reports that sender_id received or read every message of
+	// the conversation up to message_id.
+	FrameReceipt FrameType = "receipt"
+
+	// FramePresence tells friends that sender_id came online or went
+	// offline.
+	FramePresence FrameType = "presence"
+
+	// FrameEdit and FrameDelete change a message the client sent earlier.
+	// The server answers both with the changed message, to every participant.
+	FrameEdit   FrameType = "edit"
+	FrameDelete FrameType = "delete"
+
+	// FrameReactionAdd and FrameReactionRemove put or take back one emoji
+	// on a message. Accepted changes go to every participant; a change that
+	// does nothing is only echoed to the requesting connection.
+	FrameReactionAdd    FrameType = "reaction_add"
+	FrameReactionRemove FrameType = "reaction_remove"
+
+	// FrameLinkPreview carries a message again once the pages it links to
+	// were fetched; its link parts then have a preview.
+	FrameLinkPreview FrameType = "link_preview"
+
+	// Membership frames are sent by user_service when a group changes.
+	// member_id is the profile that joined or left; sender_id who did it.
+	FrameMemberAdded         FrameType = "member_added"
+	FrameMemberRemoved       FrameType = "member_removed"
+	FrameConversationRenamed FrameType = "conversation_renamed"
+)
+
+type ErrorCode string
+
+const (
+	ErrBadFrame            ErrorCode = "bad_frame"
+	ErrUnsupportedVersion  ErrorCode = "unsupported_version"
+	ErrUnknownType         ErrorCode = "unknown_type"
+	ErrInvalidConversation ErrorCode = "invalid_conversation"
+	ErrInvalidContent      ErrorCode = "invalid_content"
+	ErrNotFound            ErrorCode = "not_found"
+	ErrForbidden           ErrorCode = "forbidden"
+	ErrInvalidParent       ErrorCode = "invalid_parent"
+	ErrReactionLimit       ErrorCode = "reaction_limit"
+	ErrInternal            ErrorCode = "internal"
+)
+
+// ContentPart mirrors models.MessageContent on the wire. Attachment parts
+// reference a file uploaded through user_service and may leave Content empty.
+// Preview is set by the server only.
+type ContentPart struct {
+	ContentType  models.ContentType `json:"content_type"`
+	Content      string             `json:"content"`
+	AttachmentID uint               `json:"attachment_id,omitempty"`
+	Preview      *LinkPreview       `json:"preview,omitempty"`
+}
+
+type LinkPreview struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+type ErrorPayload struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// Envelope is every frame exchanged over the chat socket, in both directions.
+type Envelope struct {
+	Version        int               `json:"v"`
+	Type           FrameType         `json:"type"`
+	ClientMsgID    string            `json:"client_msg_id,omitempty"`
+	ConversationID uint              `json:"conversation_id,omitempty"`
+	MessageID      uint              `json:"message_id,omitempty"`
+	ParentID       uint              `json:"parent_id,omitempty"`
+	SenderID       uint              `json:"sender_id,omitempty"`
+	Content        []ContentPart     `json:"content,omitempty"`
+	Emoji          string            `json:"emoji,omitempty"`
+	Name           string            `json:"name,omitempty"`
+	MemberID       uint              `json:"member_id,omitempty"`
+	Status         ReceiptStatus     `json:"status,omitempty"`
+	Presence       models.UserStatus `json:"presence,omitempty"`
+	LastSeen       *time.Time        `json:"last_seen,omitempty"`
+	EditedAt       *time.Time        `json:"edited_at,omitempty"`
+	DeletedAt      *time.Time        `json:"deleted_at,omitempty"`
+	SentAt         *time.Time        `json:"sent_at,omitempty"`
+	ServerTime     *time.Time        `json:"server_time,omitempty"`
+	Error          *ErrorPayload     `json:"error,omitempty"`
+}
+
+// allowedContentTypes are the content types clients may send. System parts
+// are only written by user_service when a group changes.
+var allowedContentTypes = map[models.ContentType]bool{
+	models.ContentTypeText:  true,
+	models.ContentTypeLink:  true,
+	models.ContentTypeImage: true,
+	models.ContentTypeFile:  true,
+	models.ContentTypeVideo: true,
+	models.ContentTypeAudio: true,
+}
+
+// attachmentContentTypes are the content types whose parts must reference an
+// attachment.
+var attachmentContentTypes = map[models.ContentType]bool{
+	models.ContentTypeImage: true,
+	models.ContentTypeFile:  true,
+	models.ContentTypeVideo: true,
+	models.ContentTypeAudio: true,
+}
+
+// decodeEnvelope parses and validates a frame received from a client. The
+// returned *ErrorPayload is ready to be sent back as an error frame. Whether
+// the connection may use env.ConversationID is checked by the caller.
+func decodeEnvelope(data []byte) (Envelope, *ErrorPayload) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return env, &ErrorPayload{Code: ErrBadFrame, Message: "frame is not a valid JSON envelope"}
+	}
+	if env.Version != protocolVersion {
+		return env, &ErrorPayload{Code: ErrUnsupportedVersion, Message: "unsupported protocol version"}
+	}
+	if len(env.ClientMsgID) > maxClientMsgIDLength {
+		return env, &ErrorPayload{Code: ErrBadFrame, Message: "client_msg_id is too long"}
+	}
+
+	switch env.Type {
+	case FrameMessage:
+		if env.ClientMsgID == "" {
+			return env, &ErrorPayload{Code: ErrBadFrame, Message: "client_msg_id is required"}
+		}
+		if env.ConversationID == 0 {
+			return env, &ErrorPayload{Code: ErrInvalidConversation, Message: "conversation_id is required"}
+		}
+		if err := validateContent(env.Content); err != nil {
+			return env, err
+		}
+	case FrameEdit:
+		if env.ConversationID == 0 || env.MessageID == 0 {
+			return env, &ErrorPayload{Code: ErrBadFrame, Message: "conversation_id and message_id are required"}
+		}
+		if err := validateContent(env.Content); err != nil {
+			return env, err
+		}
+	case FrameDelete:
+		if env.ConversationID == 0 || env.MessageID == 0 {
+			return env, &ErrorPayload{Code: ErrBadFrame, Message: "conversation_id and message_id are required"}
+		}
+	case FrameReactionAdd, FrameReactionRemove:
+		if env.ConversationID == 0 || env.MessageID == 0 {
+			return env, &ErrorPayload{Code: ErrBadFrame, Message: "conversation_id and message_id are required"}
+		}
+		if len(env.Emoji) > maxEmojiLength || !isEmoji(env.Emoji) {
+			return env, &ErrorPayload{Code: ErrBadFrame, Message: "emoji must be a short emoji sequence"}
+		}
+	case FrameReceipt:
+		if env.ConversationID == 0 || env.MessageID == 0 {
+			return env, &ErrorPayload{Code: ErrBadFrame, Message: "conversation_id and message_id are required"}
+		}
+		if env.Status != ReceiptDelivered && env.Status != ReceiptRead {
+			return env, &ErrorPayload{Code: ErrBadFrame, Message: "status must be delivered or read"}
+		}
+	case FrameSubscribe, FrameUnsubscribe, FrameTypingStart, FrameTypingStop:
+		if env.ConversationID == 0 {
+			return env, &ErrorPayload{Code: ErrInvalidConversation, Message: "conversation_id is required"}
+		}
+	default:
+		return env, &ErrorPayload{Code: ErrUnknownType, Message: "unknown frame type"}
+	}
+	return env, nil
+}
+
+func validateContent(parts []ContentPart) *ErrorPayload {
+	if len(parts) == 0 {
+		return &ErrorPayload{Code: ErrInvalidContent, Message: "message has no content"}
+	}
+	if len(parts) > maxContentParts {
+		return &ErrorPayload{Code: ErrInvalidContent, Message: "message has too many content parts"}
+	}
+	for _, part := range parts {
+		if !allowedContentTypes[part.ContentType] {
+			return &ErrorPayload{Code: ErrInvalidContent, Message: "unsupported content_type"}
+		}
+		if attachmentContentTypes[part.ContentType] != (part.AttachmentID != 0) {
+			return &ErrorPayload{Code: ErrInvalidContent, Message: "attachment_id is required for attachment parts and only allowed on them"}
+		}
+		if !attachmentContentTypes[part.ContentType] && strings.TrimSpace(part.Content) == "" {
+			return &ErrorPayload{Code: ErrInvalidContent, Message: "content part is empty"}
+		}
+		if !utf8.ValidString(part.Content) || utf8.RuneCountInString(part.Content) > maxContentLength {
+			return &ErrorPayload{Code: ErrInvalidContent, Message: "content part is too long or not valid UTF-8"}
+		}
+	}
+	return nil
+}
+
+func encodeEnvelope(env Envelope) []byte {
+	env.Version = protocolVersion
+	data, err := json.Marshal(env)
+	if err != nil {
+		log.Printf("failed to encode envelope: %v", err)
+	}
+	return data
+}
+
+func partsFromContent(content []models.MessageContent) []ContentPart {
+	parts := make([]ContentPart, 0, len(content))
+	for _, c := range content {
+		part := ContentPart{
+			ContentType: c.ContentType,
+			Content:     c.Content,
+		}
+		if c.AttachmentID != nil {
+			part.AttachmentID = *c.AttachmentID
+		}
+		if c.LinkPreview != nil {
+			part.Preview = &LinkPreview{
+				Title:       c.LinkPreview.Title,
+				Description: c.LinkPreview.Description,
+				ImageURL:    c.LinkPreview.ImageURL,
+				SiteName:    c.LinkPreview.SiteName,
+			}
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+func contentFromParts(parts []ContentPart) []models.MessageContent {
+	content := make([]models.MessageContent, 0, len(parts))
+	for _, part := range parts {
+		c := models.MessageContent{
+			ContentType: part.ContentType,
+			Content:     part.Content,
+		}
+		if part.AttachmentID != 0 {
+			attachmentID := part.AttachmentID
+			c.AttachmentID = &attachmentID
+		}
+		content = append(content, c)
+	}
+	return content
+}
+
+func messageEnvelope(message models.Messages) Envelope {
+	env := Envelope{
+		Type:           FrameMessage,
+		ConversationID: message.ConversationID,
+		MessageID:      message.ID,
+		SenderID:       message.SenderID,
+		Content:        partsFromContent(message.Content),
+		ServerTime:     &message.CreatedAt,
+		EditedAt:       message.EditedAt,
+		DeletedAt:      message.RetractedAt,
+	}
+	if message.ClientMsgID != nil {
+		env.ClientMsgID = *message.ClientMsgID
+	}
+	if message.ParentID != nil {
+		env.ParentID = *message.ParentID
+	}
+	return env
+}
+
+// ackFrame confirms that the message identified by clientMsgID is stored.
+func ackFrame(clientMsgID string, message models.Messages) []byte {
+	return encodeEnvelope(Envelope{
+		Type:           FrameAck,
+		ClientMsgID:    clientMsgID,
+		ConversationID: message.ConversationID,
+		MessageID:      message.ID,
+		ServerTime:     &message.CreatedAt,
+	})
+}
+
+// nackFrame tells the sender the message was not stored and may be retried
+// with the same client_msg_id.
+func nackFrame(clientMsgID string, conversationID uint, payload *ErrorPayload) []byte {
+	now := time.Now()
+	return encodeEnvelope(Envelope{
+		Type:           FrameNack,
+		ClientMsgID:    clientMsgID,
+		ConversationID: conversationID,
+		ServerTime:     &now,
+		Error:          payload,
+	})
+}
+
+func controlFrame(frameType FrameType, conversationID uint) []byte {
+	now := time.Now()
+	return encodeEnvelope(Envelope{
+		Type:           frameType,
+		ConversationID: conversationID,
+		ServerTime:     &now,
+	})
+}
+
+func errorFrame(clientMsgID string, payload *ErrorPayload) []byte {
+	now := time.Now()
+	return encodeEnvelope(Envelope{
+		Type:        FrameError,
+		ClientMsgID: clientMsgID,
+		ServerTime:  &now,
+		Error:       payload,
+	})
+}
@@ -1,47 +1,257 @@
 package ws
 
+import (
+	"chat_service/internal/broker"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const publishTimeout = 5 * time.Second
+
 type Hub struct {
-	broadcast chan BroadcastMessage
+	// broker fans BroadcastMessages out to every replica, this one included.
+	broker broker.Broker
+
+	// events carries what other services publish about conversations, such
+	// as REST edits. Every replica receives them and delivers to its own
+	// connections.
+	events <-chan BroadcastMessage
+
+	reply chan clientFrame
 
-	register chan *Client
+	register chan registration
 
 	unregister chan *Client
 
-	conversations map[uint][]*Client
+	subscribe chan subscription
+
+	unsubscribe chan subscription
+
+	// conversations holds every open connection per conversation. A profile
+	// can appear several times, once per device or tab.
+	conversations map[uint]map[*Client]bool
+
+	// profiles indexes connections by profile for events that are not tied
+	// to a conversation the connection is subscribed to.
+	profiles map[uint]map[*Client]bool
+
+	// clients is the reverse index of conversations, used on unregister.
+	clients map[*Client]map[uint]bool
+
+	// sessions indexes connections by auth_service session, to close them
+	// when it is logged out.
+	sessions map[string]map[*Client]bool
+
+	// replicaID tells this replica's presence rows from other replicas'.
+	replicaID string
+
+	// pendingPresence holds the updates runPresence has not applied yet,
+	// and presenceSignal wakes it up. See queuePresence.
+	presenceMu      sync.Mutex
+	pendingPresence map[uint]presenceUpdate
+	presenceSignal  chan struct{}
+}
+
+// clientFrame is a frame addressed to a single connection, such as an error
+// in response to something that client sent.
+type clientFrame struct {
+	client *Client
+	data   []byte
+}
+
+type registration struct {
+	client          *Client
+	conversationIDs []uint
+}
+
+type subscription struct {
+	client         *Client
+	conversationID uint
 }
 
-func NewHub() *Hub {
+func NewHub(b broker.Broker, events <-chan BroadcastMessage) *Hub {
 	return &Hub{
-		broadcast:     make(chan BroadcastMessage),
-		register:      make(chan *Client),
-		unregister:    make(chan *Client),
-		conversations: make(map[uint][]*Client),
+		broker:          b,
+		events:          events,
+		reply:           make(chan clientFrame),
+		register:        make(chan registration),
+		unregister:      make(chan *Client),
+		subscribe:       make(chan subscription),
+		unsubscribe:     make(chan subscription),
+		conversations:   make(map[uint]map[*Client]bool),
+		profiles:        make(map[uint]map[*Client]bool),
+		clients:         make(map[*Client]map[uint]bool),
+		sessions:        make(map[string]map[*Client]bool),
+		replicaID:       newConnID(),
+		pendingPresence: make(map[uint]presenceUpdate),
+		presenceSignal:  make(chan struct{}, 1),
+	}
+}
+
+func addToIndex[K comparable](index map[K]map[*Client]bool, key K, client *Client) {
+	clients, ok := index[key]
+	if !ok {
+		clients = make(map[*Client]bool)
+		index[key] = clients
+	}
+	clients[client] = true
+}
+
+func removeFromIndex[K comparable](index map[K]map[*Client]bool, key K, client *Client) {
+	clients, ok := index[key]
+	if !ok {
+		return
+	}
+	delete(clients, client)
+	if len(clients) == 0 {
+		delete(index, key)
+	}
+}
+
+// publish hands a message to the broker. It is called from client
+// goroutines, never from Hubrun, which consumes the broker's output.
+func (h *Hub) publish(message BroadcastMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	if err := h.broker.Publish(ctx, message); err != nil {
+		log.Printf("failed to publish message for conversation %d: %v", message.ConversationID, err)
+	}
+}
+
+func (h *Hub) isRegistered(client *Client) bool {
+	_, ok := h.clients[client]
+	return ok
+}
+
+func (h *Hub) add(reg registration) {
+	subscribed := make(map[uint]bool, len(reg.conversationIDs))
+	for _, conversationID := range reg.conversationIDs {
+		subscribed[conversationID] = true
+		addToIndex(h.conversations, conversationID, reg.client)
+	}
+	h.clients[reg.client] = subscribed
+
+	profileID := reg.client.profileID
+	if len(h.profiles[profileID]) == 0 {
+		h.queuePresence(presenceUpdate{profileID: profileID, online: true, at: time.Now()})
+	}
+	addToIndex(h.profiles, profileID, reg.client)
+	addToIndex(h.sessions, reg.client.sessionID, reg.client)
+}
+
+// remove drops a single connection and closes its send channel, leaving the
+// other connections of its conversations and profile untouched.
+func (h *Hub) remove(client *Client) {
+	subscribed, ok := h.clients[client]
+	if !ok {
+		return
+	}
+	for conversationID := range subscribed {
+		removeFromIndex(h.conversations, conversationID, client)
+	}
+	removeFromIndex(h.profiles, client.profileID, client)
+	removeFromIndex(h.sessions, client.sessionID, client)
+	delete(h.clients, client)
+	close(client.send)
+
+	if len(h.profiles[client.profileID]) == 0 {
+		h.queuePresence(presenceUpdate{profileID: client.profileID, online: false, at: time.Now()})
 	}
 }
 
 func (h *Hub) Hubrun() {
+	go h.runPresence()
+
 	for {
 		select {
-		case client := <-h.register:
-			h.conversations[client.conversationID] = append(h.conversations[client.conversationID], client)
+		case reg := <-h.register:
+			h.add(reg)
 		case client := <-h.unregister:
-			if _, ok := h.conversations[client.conversationID]; ok {
-				delete(h.conversations, client.conversationID)
-				close(client.send)
+			h.remove(client)
+		case sub := <-h.subscribe:
+			if subscribed, ok := h.clients[sub.client]; ok {
+				subscribed[sub.conversationID] = true
+				addToIndex(h.conversations, sub.conversationID, sub.client)
+			}
+		case sub := <-h.unsubscribe:
+			if subscribed, ok := h.clients[sub.client]; ok {
+				delete(subscribed, sub.conversationID)
+				removeFromIndex(h.conversations, sub.conversationID, sub.client)
+			}
+		case frame := <-h.reply:
+			if !h.isRegistered(frame.client) {
+				continue
+			}
+			select {
+			case frame.client.send <- frame.data:
+			default:
 			}
-		case message := <-h.broadcast:
-			clients := h.conversations[message.ConversationID]
-			for _, client := range clients {
-				if client.profileID == message.SenderID {
-					continue
-				}
-				select {
-				case client.send <- message.Data:
-				default:
-					close(client.send)
-					delete(h.conversations, client.conversationID)
-				}
+		case message := <-h.broker.Messages():
+			h.deliver(message)
+		case message := <-h.events:
+			h.deliver(message)
+			if message.PreviewMessage != 0 {
+				go h.attachPreviews(message.PreviewMessage)
 			}
 		}
 	}
 }
+
+// deliver sends a message to the connections it targets on this replica.
+func (h *Hub) deliver(message BroadcastMessage) {
+	if len(message.RevokedSessions) > 0 {
+		for _, sessionID := range message.RevokedSessions {
+			for client := range h.sessions[sessionID] {
+				h.remove(client)
+			}
+		}
+		return
+	}
+	if message.MemberAdded != 0 {
+		h.subscribeMember(message.ConversationID, message.MemberAdded)
+	}
+	targets := h.conversations[message.ConversationID]
+	if message.ProfileID != 0 {
+		targets = h.profiles[message.ProfileID]
+	}
+	for client := range targets {
+		// The sending connection already has an ack; the sender's other
+		// devices still need the message itself.
+		if client.id == message.SenderConnID {
+			continue
+		}
+		select {
+		case client.send <- message.Data:
+		default:
+			h.remove(client)
+		}
+	}
+	if message.MemberRemoved != 0 {
+		h.unsubscribeMember(message.ConversationID, message.MemberRemoved)
+	}
+}
+
+// subscribeMember adds a profile that just joined a conversation to it on
+// every user-scoped connection of that profile.
+func (h *Hub) subscribeMember(conversationID, profileID uint) {
+	for client := range h.profiles[profileID] {
+		if !client.userScoped {
+			continue
+		}
+		h.clients[client][conversationID] = true
+		addToIndex(h.conversations, conversationID, client)
+		client.setSubscribed(conversationID, true)
+	}
+}
+
+// unsubscribeMember drops a conversation from every connection of a profile
+// that is no longer a member, so it neither receives nor sends there.
+func (h *Hub) unsubscribeMember(conversationID, profileID uint) {
+	for client := range h.profiles[profileID] {
+		delete(h.clients[client], conversationID)
+		removeFromIndex(h.conversations, conversationID, client)
+		client.setSubscribed(conversationID, false)
+	}
+}
@@ -0,0 +1,33 @@
+package ws
+
+import (
+	"chat_service/internal/broker"
+	"testing"
+)
+
+func TestDeliverClosesRevokedSessions(t *testing.T) {
+	h := NewHub(broker.NewLocal(), nil)
+	newClient := func(sessionID string) *Client {
+		client := &Client{id: newConnID(), profileID: 1, sessionID: sessionID, send: make(chan []byte, 1)}
+		h.add(registration{client: client, conversationIDs: []uint{7}})
+		return client
+	}
+	revoked, kept := newClient("revoked"), newClient("kept")
+
+	h.deliver(BroadcastMessage{RevokedSessions: []string{"revoked"}})
+
+	if _, ok := <-revoked.send; ok {
+		t.Error("revoked session's connection is still open")
+	}
+	if h.isRegistered(revoked) || len(h.sessions["revoked"]) != 0 {
+		t.Error("revoked session's connection is still registered")
+	}
+	if !h.isRegistered(kept) || !h.conversations[7][kept] {
+		t.Error("other session's connection was dropped")
+	}
+	select {
+	case <-kept.send:
+		t.Error("revocation was delivered as a frame")
+	default:
+	}
+}
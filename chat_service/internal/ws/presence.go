@@ -0,0 +1,188 @@
+package ws
+
+import (
+	"chat_service/internal/database"
+	"chat_service/internal/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// presenceHeartbeat is how often a replica refreshes the presence rows
+	// of the profiles it holds connections for.
+	presenceHeartbeat = 30 * time.Second
+
+	// presenceTTL is how long a presence row counts without a heartbeat,
+	// after which its replica is assumed to be gone.
+	presenceTTL = 3 * presenceHeartbeat
+)
+
+// presenceUpdate is emitted by the hub when a profile opens its first
+// connection or closes its last one on this replica. Replicas share their
+// counts through models.PresenceConnection, so a profile only goes offline
+// once no replica holds a connection of it.
+type presenceUpdate struct {
+	profileID uint
+	online    bool
+	at        time.Time
+}
+
+// friendsQuery lists the profiles that share a one-to-one conversation with
+// the profile, which is what accepting a friend request creates.
+const friendsQuery = `
+SELECT DISTINCT others.user_id
+FROM conversation_members AS mine
+JOIN conversations ON conversations.id = mine.conversation_id
+JOIN conversation_members AS others ON others.conversation_id = mine.conversation_id
+WHERE mine.user_id = @profile AND others.user_id <> @profile
+	AND conversations.conversation_type = @type
+	AND mine.deleted_at IS NULL AND others.deleted_at IS NULL AND conversations.deleted_at IS NULL`
+
+// queuePresence hands an update to runPresence without blocking, so Hubrun
+// never waits on it. Only the latest pending update of a profile is kept.
+func (h *Hub) queuePresence(update presenceUpdate) {
+	h.presenceMu.Lock()
+	h.pendingPresence[update.profileID] = update
+	h.presenceMu.Unlock()
+
+	select {
+	case h.presenceSignal <- struct{}{}:
+	default:
+	}
+}
+
+func (h *Hub) takePresence() map[uint]presenceUpdate {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+	pending := h.pendingPresence
+	h.pendingPresence = make(map[uint]presenceUpdate)
+	return pending
+}
+
+// runPresence applies presence updates off the hub goroutine, so database
+// writes and broker publishes never stall fan-out, and keeps this replica's
+// presence rows alive.
+func (h *Hub) runPresence() {
+	ticker := time.NewTicker(presenceHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.presenceSignal:
+			for _, update := range h.takePresence() {
+				if err := h.applyPresence(update); err != nil {
+					log.Printf("failed to update presence of profile %d: %v", update.profileID, err)
+				}
+			}
+		case now := <-ticker.C:
+			if err := h.heartbeat(now); err != nil {
+				log.Printf("failed to refresh presence: %v", err)
+			}
+		}
+	}
+}
+
+func (h *Hub) applyPresence(update presenceUpdate) error {
+	if update.online {
+		if err := database.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "replica_id"}, {Name: "profile_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"seen_at"}),
+		}).Create(&models.PresenceConnection{
+			ReplicaID: h.replicaID,
+			ProfileID: update.profileID,
+			SeenAt:    update.at,
+		}).Error; err != nil {
+			return err
+		}
+	} else if err := database.DB.
+		Where("replica_id = ? AND profile_id = ?", h.replicaID, update.profileID).
+		Delete(&models.PresenceConnection{}).Error; err != nil {
+		return err
+	}
+
+	// Another replica holding a connection already reported the profile
+	// online, and keeps it so.
+	elsewhere, err := connectedElsewhere(h.replicaID, update.profileID, update.at)
+	if err != nil || elsewhere {
+		return err
+	}
+	return h.setPresence(update.profileID, update.online, update.at)
+}
+
+// connectedElsewhere reports whether a replica other than replicaID holds a
+// connection of the profile.
+func connectedElsewhere(replicaID string, profileID uint, now time.Time) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.PresenceConnection{}).
+		Where("profile_id = ? AND replica_id <> ? AND seen_at > ?", profileID, replicaID, now.Add(-presenceTTL)).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// heartbeat refreshes this replica's presence rows and reports offline the
+// profiles whose only rows belonged to replicas that stopped heartbeating.
+// DELETE ... RETURNING hands each stale row to a single replica.
+func (h *Hub) heartbeat(now time.Time) error {
+	if err := database.DB.Model(&models.PresenceConnection{}).
+		Where("replica_id = ?", h.replicaID).
+		Update("seen_at", now).Error; err != nil {
+		return err
+	}
+
+	var stale []uint
+	if err := database.DB.Raw("DELETE FROM presence_connections WHERE seen_at < ? RETURNING profile_id",
+		now.Add(-presenceTTL)).Scan(&stale).Error; err != nil {
+		return err
+	}
+	for _, profileID := range stale {
+		connected, err := connectedElsewhere("", profileID, now)
+		if err != nil {
+			return err
+		}
+		if connected {
+			continue
+		}
+		if err := h.setPresence(profileID, false, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setPresence stores a profile's status and tells its friends.
+func (h *Hub) setPresence(profileID uint, online bool, at time.Time) error {
+	status := models.StatusInactive
+	if online {
+		status = models.StatusActive
+	}
+
+	if err := database.DB.Model(&models.Profile{}).
+		Where("id = ?", profileID).
+		Updates(map[string]interface{}{"status": status, "last_seen": at}).Error; err != nil {
+		return err
+	}
+
+	var friendIDs []uint
+	if err := database.DB.Raw(friendsQuery, map[string]interface{}{"profile": profileID, "type": models.OneToOne}).
+		Scan(&friendIDs).Error; err != nil {
+		return err
+	}
+
+	data := encodeEnvelope(Envelope{
+		Type:       FramePresence,
+		SenderID:   profileID,
+		Presence:   status,
+		LastSeen:   &at,
+		ServerTime: &at,
+	})
+	for _, friendID := range friendIDs {
+		h.publish(BroadcastMessage{
+			ProfileID: friendID,
+			Data:      data,
+			SenderID:  profileID,
+		})
+	}
+	return nil
+}
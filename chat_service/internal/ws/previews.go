@@ -0,0 +1,260 @@
+package ws
+
+import (
+	"chat_service/internal/database"
+	"chat_service/internal/linkpreview"
+	"chat_service/internal/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxPreviewsPerMessage = 3
+	maxConcurrentPreviews = 8
+
+	previewCacheTTL   = 24 * time.Hour
+	previewFailureTTL = time.Hour
+)
+
+var (
+	previewFetcher = linkpreview.New(linkpreview.Config{})
+
+	// previewSlots bounds how many pages are fetched at once across all
+	// connections.
+	previewSlots = make(chan struct{}, maxConcurrentPreviews)
+
+	urlPattern = regexp.MustCompile("https?://[^\\s<>\"'`]+")
+)
+
+// previewURLs lists the URLs of a message worth previewing: link parts
+// first, then URLs written in text parts.
+func previewURLs(content []models.MessageContent) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	add := func(u string) {
+		u = strings.TrimRight(strings.TrimSpace(u), ".,;:!?)]}'\"")
+		if u == "" || seen[u] || len(urls) >= maxPreviewsPerMessage {
+			return
+		}
+		seen[u] = true
+		urls = append(urls, u)
+	}
+
+	for _, c := range content {
+		if c.ContentType == models.ContentTypeLink {
+			add(c.Content)
+		}
+	}
+	for _, c := range content {
+		if c.ContentType == models.ContentTypeText {
+			for _, u := range urlPattern.FindAllString(c.Content, -1) {
+				add(u)
+			}
+		}
+	}
+	return urls
+}
+
+func urlHash(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadPreview returns the cached preview of rawURL, fetching the page when
+// there is no entry or it went stale.
+func loadPreview(rawURL string) (models.LinkPreview, error) {
+	hash := urlHash(rawURL)
+
+	var cached models.LinkPreview
+	err := database.DB.Where("url_hash = ?", hash).First(&cached).Error
+	if err == nil {
+		ttl := previewCacheTTL
+		if cached.Failed {
+			ttl = previewFailureTTL
+		}
+		if time.Since(cached.FetchedAt) < ttl {
+			return cached, nil
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return cached, err
+	}
+
+	previewSlots <- struct{}{}
+	preview, fetchErr := previewFetcher.Fetch(context.Background(), rawURL)
+	<-previewSlots
+	if errors.Is(fetchErr, linkpreview.ErrUnsupportedURL) {
+		// Never reached the network; nothing worth caching.
+		return models.LinkPreview{}, fetchErr
+	}
+
+	row := models.LinkPreview{
+		URLHash:   hash,
+		URL:       rawURL,
+		Failed:    fetchErr != nil,
+		FetchedAt: time.Now(),
+	}
+	if fetchErr == nil {
+		row.Title = preview.Title
+		row.Description = preview.Description
+		row.ImageURL = preview.ImageURL
+		row.SiteName = preview.SiteName
+	}
+	err = database.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "url_hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"title", "description", "image_url", "site_name", "failed", "fetched_at", "updated_at",
+		}),
+	}).Create(&row).Error
+	return row, err
+}
+
+// keepLinkPreviews gives the link parts of content the preview the link part
+// with the same URL had in previous, so an edit that keeps a link keeps its
+// preview. attachPreviews fetches the others; the
+// REST edit in user_service keeps previews the same way.
+func keepLinkPreviews(previous, content []models.MessageContent) {
+	previewIDs := make(map[string]uint)
+	for _, row := range previous {
+		if row.ContentType == models.ContentTypeLink && row.LinkPreviewID != nil {
+			previewIDs[strings.TrimSpace(row.Content)] = *row.LinkPreviewID
+		}
+	}
+	for i := range content {
+		if content[i].ContentType != models.ContentTypeLink {
+			continue
+		}
+		if previewID, ok := previewIDs[strings.TrimSpace(content[i].Content)]; ok {
+			content[i].LinkPreviewID = &previewID
+		}
+	}
+}
+
+// storePreviews links previews to the message's link parts, adding a link
+// part for URLs that only appear in text. The message is read again under a
+// row lock, so URLs an edit removed in the meantime are left out.
+func storePreviews(messageID uint, previews map[string]models.LinkPreview) (message models.Messages, changed bool, err error) {
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Content").
+		Where("id = ?", messageID).
+		First(&message).Error; err != nil {
+		tx.Rollback()
+		return message, false, err
+	}
+	if message.RetractedAt != nil {
+		tx.Rollback()
+		return message, false, nil
+	}
+
+	for _, u := range previewURLs(message.Content) {
+		preview, ok := previews[u]
+		if !ok {
+			continue
+		}
+
+		linked := false
+		for _, part := range message.Content {
+			if part.ContentType != models.ContentTypeLink || strings.TrimSpace(part.Content) != u {
+				continue
+			}
+			linked = true
+			if part.LinkPreviewID != nil && *part.LinkPreviewID == preview.ID {
+				break
+			}
+			if err := tx.Model(&part).Update("link_preview_id", preview.ID).Error; err != nil {
+				tx.Rollback()
+				return message, false, err
+			}
+			changed = true
+			break
+		}
+		if linked {
+			continue
+		}
+
+		previewID := preview.ID
+		if err := tx.Create(&models.MessageContent{
+			MessageID:     message.ID,
+			ContentType:   models.ContentTypeLink,
+			Content:       u,
+			LinkPreviewID: &previewID,
+		}).Error; err != nil {
+			tx.Rollback()
+			return message, false, err
+		}
+		changed = true
+	}
+
+	if !changed {
+		tx.Rollback()
+		return message, false, nil
+	}
+	if err := tx.Commit().Error; err != nil {
+		return message, false, err
+	}
+
+	message = models.Messages{}
+	err = database.DB.Preload("Content.LinkPreview").Where("id = ?", messageID).First(&message).Error
+	return message, true, err
+}
+
+// attachPreviews fetches the pages a stored message links to and, when any
+// preview is new, sends the message again as a link_preview frame. It runs in
+// its own goroutine so a slow site never holds up the chat. Callers only
+// start it when previewURLs finds something in the content they stored.
+func (h *Hub) attachPreviews(messageID uint) {
+	var message models.Messages
+	if err := database.DB.Preload("Content").Where("id = ?", messageID).First(&message).Error; err != nil {
+		log.Printf("failed to load message %d for link previews: %v", messageID, err)
+		return
+	}
+
+	previews := make(map[string]models.LinkPreview)
+	for _, u := range previewURLs(message.Content) {
+		preview, err := loadPreview(u)
+		if err != nil {
+			if !errors.Is(err, linkpreview.ErrUnsupportedURL) {
+				log.Printf("failed to load link preview: %v", err)
+			}
+			continue
+		}
+		if !preview.Failed {
+			previews[u] = preview
+		}
+	}
+	if len(previews) == 0 {
+		return
+	}
+
+	message, changed, err := storePreviews(messageID, previews)
+	if err != nil {
+		log.Printf("failed to store link previews for message %d: %v", messageID, err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	env := messageEnvelope(message)
+	env.Type = FrameLinkPreview
+	h.publish(BroadcastMessage{
+		ConversationID: message.ConversationID,
+		Data:           encodeEnvelope(env),
+		SenderID:       message.SenderID,
+	})
+}
@@ -0,0 +1,54 @@
+package ws
+
+import (
+	"chat_service/internal/models"
+	"testing"
+)
+
+func TestKeepLinkPreviews(t *testing.T) {
+	previewID := uint(7)
+	previous := []models.MessageContent{
+		{ContentType: models.ContentTypeText, Content: "see https://example.com"},
+		{ContentType: models.ContentTypeLink, Content: "https://example.com", LinkPreviewID: &previewID},
+		{ContentType: models.ContentTypeLink, Content: "https://removed.example.com", LinkPreviewID: &previewID},
+	}
+	content := []models.MessageContent{
+		{ContentType: models.ContentTypeText, Content: "https://example.com"},
+		{ContentType: models.ContentTypeLink, Content: " https://example.com "},
+		{ContentType: models.ContentTypeLink, Content: "https://new.example.com"},
+	}
+
+	keepLinkPreviews(previous, content)
+
+	if content[0].LinkPreviewID != nil {
+		t.Errorf("text part got preview %d", *content[0].LinkPreviewID)
+	}
+	if content[1].LinkPreviewID == nil || *content[1].LinkPreviewID != previewID {
+		t.Errorf("kept link lost its preview: %v", content[1].LinkPreviewID)
+	}
+	if content[2].LinkPreviewID != nil {
+		t.Errorf("new link got preview %d", *content[2].LinkPreviewID)
+	}
+}
+
+func TestPreviewURLs(t *testing.T) {
+	content := []models.MessageContent{
+		{ContentType: models.ContentTypeText, Content: "read https://a.example.com/x. and (https://b.example.com)"},
+		{ContentType: models.ContentTypeLink, Content: "https://b.example.com"},
+		{ContentType: models.ContentTypeText, Content: "no links here"},
+	}
+	got := previewURLs(content)
+	want := []string{"https://b.example.com", "https://a.example.com/x"}
+	if len(got) != len(want) {
+		t.Fatalf("previewURLs = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("previewURLs[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if got := previewURLs([]models.MessageContent{{ContentType: models.ContentTypeText, Content: "hello"}}); len(got) != 0 {
+		t.Errorf("previewURLs without links = %q", got)
+	}
+}
@@ -0,0 +1,154 @@
+package ws
+
+import (
+	"chat_service/internal/database"
+	"chat_service/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxReactionsPerUser caps how many distinct emoji one participant can put
+// on a single message.
+const maxReactionsPerUser = 5
+
+var errReactionLimit = fmt.Errorf("a message can carry at most %d reactions from one participant", maxReactionsPerUser)
+
+// loadReactableMessage fetches a live message of the conversation. The row is
+// locked so concurrent reactions by the same participant cannot both pass
+// the limit check.
+func loadReactableMessage(tx *gorm.DB, messageID, conversationID uint) (models.Messages, error) {
+	var message models.Messages
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND conversation_id = ?", messageID, conversationID).
+		First(&message).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return message, errMessageNotFound
+		}
+		return message, err
+	}
+	if message.RetractedAt != nil {
+		return message, errRetracted
+	}
+	return message, nil
+}
+
+// addReactionInDB stores the reaction unless the participant already put the
+// same emoji on the message; added reports whether a row was created.
+func addReactionInDB(messageID, conversationID, profileID uint, emoji string) (added bool, err error) {
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	message, err := loadReactableMessage(tx, messageID, conversationID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	var own []string
+	if err := tx.Model(&models.MessageReaction{}).
+		Where("message_id = ? AND profile_id = ?", message.ID, profileID).
+		Pluck("emoji", &own).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	for _, e := range own {
+		if e == emoji {
+			tx.Rollback()
+			return false, nil
+		}
+	}
+	if len(own) >= maxReactionsPerUser {
+		tx.Rollback()
+		return false, errReactionLimit
+	}
+
+	if err := tx.Create(&models.MessageReaction{
+		MessageID: message.ID,
+		ProfileID: profileID,
+		Emoji:     emoji,
+	}).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	return true, tx.Commit().Error
+}
+
+// removeReactionInDB deletes the participant's reaction; removed is false when
+// there was nothing to delete.
+func removeReactionInDB(messageID, conversationID, profileID uint, emoji string) (removed bool, err error) {
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	message, err := loadReactableMessage(tx, messageID, conversationID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	result := tx.Unscoped().
+		Where("message_id = ? AND profile_id = ? AND emoji = ?", message.ID, profileID, emoji).
+		Delete(&models.MessageReaction{})
+	if result.Error != nil {
+		tx.Rollback()
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, tx.Commit().Error
+}
+
+func (c *Client) handleReaction(env Envelope) {
+	if !c.isSubscribed(env.ConversationID) {
+		c.replyFrame(errorFrame(env.ClientMsgID, &ErrorPayload{
+			Code:    ErrInvalidConversation,
+			Message: "not subscribed to this conversation",
+		}))
+		return
+	}
+
+	var changed bool
+	var err error
+	if env.Type == FrameReactionAdd {
+		changed, err = addReactionInDB(env.MessageID, env.ConversationID, c.profileID, env.Emoji)
+	} else {
+		changed, err = removeReactionInDB(env.MessageID, env.ConversationID, c.profileID, env.Emoji)
+	}
+	if errors.Is(err, errReactionLimit) {
+		c.replyFrame(errorFrame(env.ClientMsgID, &ErrorPayload{Code: ErrReactionLimit, Message: err.Error()}))
+		return
+	}
+	if err != nil {
+		c.replyFrame(errorFrame(env.ClientMsgID, changeErrorPayload(err)))
+		return
+	}
+
+	now := time.Now()
+	frame := encodeEnvelope(Envelope{
+		Type:           env.Type,
+		ClientMsgID:    env.ClientMsgID,
+		ConversationID: env.ConversationID,
+		MessageID:      env.MessageID,
+		SenderID:       c.profileID,
+		Emoji:          env.Emoji,
+		ServerTime:     &now,
+	})
+	if !changed {
+		c.replyFrame(frame)
+		return
+	}
+	c.hub.publish(BroadcastMessage{
+		ConversationID: env.ConversationID,
+		Data:           frame,
+		SenderID:       c.profileID,
+	})
+}
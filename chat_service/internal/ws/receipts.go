@@ -0,0 +1,54 @@
+package ws
+
+import (
+	"chat_service/internal/database"
+	"log"
+	"time"
+)
+
+type ReceiptStatus string
+
+const (
+	ReceiptDelivered ReceiptStatus = "delivered"
+	ReceiptRead      ReceiptStatus = "read"
+)
+
+// recordReceipts marks every message of the conversation up to upTo as
+// delivered to, or read by, profileID. The record_receipts function created
+// by the user_service migrations holds the SQL, so both services write
+// receipts the same way.
+func recordReceipts(conversationID, profileID, upTo uint, status ReceiptStatus) error {
+	return database.DB.Exec("SELECT record_receipts(?, ?, ?, ?, ?)",
+		conversationID, profileID, upTo, status == ReceiptRead, time.Now()).Error
+}
+
+func (c *Client) handleReceipt(env Envelope) {
+	if !c.isSubscribed(env.ConversationID) {
+		c.replyFrame(errorFrame(env.ClientMsgID, &ErrorPayload{
+			Code:    ErrInvalidConversation,
+			Message: "not subscribed to this conversation",
+		}))
+		return
+	}
+
+	if err := recordReceipts(env.ConversationID, c.profileID, env.MessageID, env.Status); err != nil {
+		log.Printf("failed to record receipts: %v", err)
+		c.replyFrame(errorFrame(env.ClientMsgID, &ErrorPayload{Code: ErrInternal, Message: "failed to record receipt"}))
+		return
+	}
+
+	now := time.Now()
+	c.hub.publish(BroadcastMessage{
+		ConversationID: env.ConversationID,
+		Data: encodeEnvelope(Envelope{
+			Type:           FrameReceipt,
+			ConversationID: env.ConversationID,
+			MessageID:      env.MessageID,
+			SenderID:       c.profileID,
+			Status:         env.Status,
+			ServerTime:     &now,
+		}),
+		SenderID:     c.profileID,
+		SenderConnID: c.id,
+	})
+}
@@ -0,0 +1,41 @@
+package ws
+
+import (
+	"chat_service/internal/database"
+	"chat_service/internal/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// activeSessionCondition matches tickets whose auth_service session was
+// neither logged out nor expired.
+const activeSessionCondition = "EXISTS (SELECT 1 FROM sessions " +
+	"WHERE sessions.session_id = ws_tickets.session_id " +
+	"AND sessions.revoked_at IS NULL AND sessions.expires_at > ? AND sessions.deleted_at IS NULL)"
+
+// redeemTicket consumes a ticket minted by auth_service. The conditional
+// update makes redemption atomic, so a ticket can open at most one socket,
+// and only while its session is active.
+func redeemTicket(ticket string) (models.WsTicket, error) {
+	sum := sha256.Sum256([]byte(ticket))
+	ticketHash := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	result := database.DB.Model(&models.WsTicket{}).
+		Where("ticket_hash = ? AND used_at IS NULL AND expires_at > ?", ticketHash, now).
+		Where(activeSessionCondition, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return models.WsTicket{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.WsTicket{}, errInvalidToken
+	}
+
+	var wsTicket models.WsTicket
+	if err := database.DB.Where("ticket_hash = ?", ticketHash).First(&wsTicket).Error; err != nil {
+		return models.WsTicket{}, err
+	}
+	return wsTicket, nil
+}
@@ -0,0 +1,114 @@
+package ws
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// typingThrottle is the minimum gap between relayed typing_start frames
+	// for one connection and conversation; repeats in between only keep the
+	// indicator alive.
+	typingThrottle = 3 * time.Second
+
+	// typingTTL expires an indicator whose client stopped refreshing it
+	// without sending typing_stop.
+	typingTTL = 8 * time.Second
+)
+
+type typingState struct {
+	lastRelayed time.Time
+	timer       *time.Timer
+}
+
+// typingTracker holds the typing indicators of one connection. Expiry timers
+// run on their own goroutines, hence the mutex.
+type typingTracker struct {
+	mu     sync.Mutex
+	states map[uint]*typingState
+}
+
+func (c *Client) handleTyping(env Envelope) {
+	if !c.isSubscribed(env.ConversationID) {
+		c.replyFrame(errorFrame(env.ClientMsgID, &ErrorPayload{
+			Code:    ErrInvalidConversation,
+			Message: "not subscribed to this conversation",
+		}))
+		return
+	}
+
+	if env.Type == FrameTypingStop {
+		c.stopTyping(env.ConversationID)
+		return
+	}
+
+	conversationID := env.ConversationID
+	now := time.Now()
+
+	c.typing.mu.Lock()
+	if c.typing.states == nil {
+		c.typing.states = make(map[uint]*typingState)
+	}
+	state, ok := c.typing.states[conversationID]
+	if !ok {
+		state = &typingState{
+			timer: time.AfterFunc(typingTTL, func() { c.stopTyping(conversationID) }),
+		}
+		c.typing.states[conversationID] = state
+	} else {
+		state.timer.Reset(typingTTL)
+	}
+	relay := now.Sub(state.lastRelayed) >= typingThrottle
+	if relay {
+		state.lastRelayed = now
+	}
+	c.typing.mu.Unlock()
+
+	if relay {
+		c.publishTyping(FrameTypingStart, conversationID)
+	}
+}
+
+// stopTyping clears the indicator and tells the other participants, unless
+// it had already been cleared.
+func (c *Client) stopTyping(conversationID uint) {
+	c.typing.mu.Lock()
+	state, ok := c.typing.states[conversationID]
+	if ok {
+		state.timer.Stop()
+		delete(c.typing.states, conversationID)
+	}
+	c.typing.mu.Unlock()
+
+	if ok {
+		c.publishTyping(FrameTypingStop, conversationID)
+	}
+}
+
+func (c *Client) stopAllTyping() {
+	c.typing.mu.Lock()
+	conversationIDs := make([]uint, 0, len(c.typing.states))
+	for conversationID := range c.typing.states {
+		conversationIDs = append(conversationIDs, conversationID)
+	}
+	c.typing.mu.Unlock()
+
+	for _, conversationID := range conversationIDs {
+		c.stopTyping(conversationID)
+	}
+}
+
+func (c *Client) publishTyping(frameType FrameType, conversationID uint) {
+	now := time.Now()
+	c.hub.publish(BroadcastMessage{
+		ConversationID: conversationID,
+		Data: encodeEnvelope(Envelope{
+			Type:           frameType,
+			ConversationID: conversationID,
+			SenderID:       c.profileID,
+			ServerTime:     &now,
+		}),
+		SenderID:     c.profileID,
+		SenderConnID: c.id,
+	})
+}
@@ -14,27 +14,74 @@ var wg = &sync.WaitGroup{}
 var Wsupgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    []string{bearerSubprotocol},
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
 }
 
+// Wshandler upgrades an authenticated request to a chat socket. Without a
+// conversation the socket is user-scoped: it subscribes to every conversation
+// of the profile and receives profile-level events. A lastMessageId replays
+// everything newer before live traffic starts.
 func Wshandler(hub *Hub, w http.ResponseWriter, r *http.Request) {
-	conversationID := r.URL.Query().Get("conversationId")
-	profileID := r.URL.Query().Get("profileId")
-	if conversationID == "" {
-		http.Error(w, "Missing conversationId", http.StatusBadRequest)
-		return
+	var conversationID, profileID, lastMessageID uint
+	var sessionID string
+
+	if lastMessageIDParam := r.URL.Query().Get("lastMessageId"); lastMessageIDParam != "" {
+		num, err := strconv.ParseUint(lastMessageIDParam, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid lastMessageId", http.StatusBadRequest)
+			return
+		}
+		lastMessageID = uint(num)
 	}
-	num, err := strconv.ParseUint(conversationID, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid conversationId", http.StatusBadRequest)
-		return
+
+	if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+		wsTicket, err := redeemTicket(ticket)
+		if err != nil {
+			log.Printf("websocket ticket rejected: %v", err)
+			http.Error(w, http.StatusText(authStatus(err)), authStatus(err))
+			return
+		}
+		conversationID = wsTicket.ConversationID
+		profileID = wsTicket.ProfileID
+		sessionID = wsTicket.SessionID
+	} else {
+		if conversationIDParam := r.URL.Query().Get("conversationId"); conversationIDParam != "" {
+			num, err := strconv.ParseUint(conversationIDParam, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid conversationId", http.StatusBadRequest)
+				return
+			}
+			conversationID = uint(num)
+		}
+
+		profile, session, err := authenticate(r)
+		if err != nil {
+			log.Printf("websocket authentication failed: %v", err)
+			http.Error(w, http.StatusText(authStatus(err)), authStatus(err))
+			return
+		}
+		profileID = profile.ID
+		sessionID = session
 	}
 
-	profileIDNum, err := strconv.ParseUint(profileID, 10, 64)
+	var conversationIDs []uint
+	var err error
+	if conversationID != 0 {
+		var ok bool
+		ok, err = isParticipant(conversationID, profileID)
+		if err == nil && !ok {
+			err = errNotParticipant
+		}
+		conversationIDs = []uint{conversationID}
+	} else {
+		conversationIDs, err = participantConversations(profileID)
+	}
 	if err != nil {
-		http.Error(w, "Invalid profileId", http.StatusBadRequest)
+		log.Printf("websocket authorization failed for profile %d: %v", profileID, err)
+		http.Error(w, http.StatusText(authStatus(err)), authStatus(err))
 		return
 	}
 
@@ -43,18 +90,29 @@ func Wshandler(hub *Hub, w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 		return
 	}
+
+	subscriptions := make(map[uint]bool, len(conversationIDs))
+	for _, id := range conversationIDs {
+		subscriptions[id] = true
+	}
 	client := &Client{
-		hub:            hub,
-		conn:           conn,
-		send:           make(chan []byte, 256),
-		conversationID: uint(num),
-		profileID:      uint(profileIDNum),
+		id:            newConnID(),
+		hub:           hub,
+		conn:          conn,
+		send:          make(chan []byte, 256),
+		profileID:     profileID,
+		sessionID:     sessionID,
+		subscriptions: subscriptions,
+		userScoped:    conversationID == 0,
+
+		conversationIDs: conversationIDs,
+		replayAfter:     lastMessageID,
 	}
-	client.hub.register <- client
 
 	wg.Add(2)
 
+	// writePump registers the client and starts readPump once any replay
+	// is done.
 	go client.writePump()
-	go client.readPump()
 	wg.Wait()
 }
@@ -16,7 +16,23 @@ func UsersRoutes(router *gin.RouterGroup) *gin.RouterGroup {
 	router.PUT("/accept_friend_request", handlers.AcceptFriendRequest)
 	router.GET("/get_friends", handlers.GetFriends)
 	router.GET("/get_conversation", handlers.GetConversation)
+	router.GET("/list_conversations", handlers.ListConversations)
 	router.GET("/get_messages", handlers.GetMessages)
+	router.GET("/get_thread", handlers.GetThread)
+	router.PUT("/mark_conversation_read", handlers.MarkConversationRead)
+	router.PUT("/edit_message", handlers.EditMessage)
+	router.DELETE("/delete_message", handlers.DeleteMessage)
+	router.POST("/create_attachment_upload", handlers.CreateAttachmentUpload)
+	router.PUT("/upload_attachment_chunk", handlers.UploadAttachmentChunk)
+	router.POST("/complete_attachment_upload", handlers.CompleteAttachmentUpload)
+	router.GET("/download_attachment", handlers.DownloadAttachment)
+	router.POST("/create_group", handlers.CreateGroup)
+	router.PUT("/rename_group", handlers.RenameGroup)
+	router.PUT("/add_group_members", handlers.AddGroupMembers)
+	router.DELETE("/remove_group_member", handlers.RemoveGroupMember)
+	router.DELETE("/leave_group", handlers.LeaveGroup)
+	router.PUT("/set_group_member_role", handlers.SetGroupMemberRole)
+	router.PUT("/set_group_avatar", handlers.SetGroupAvatar)
 
 	return router
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"user_service/api"
 	"user_service/internal/database"
+	"user_service/internal/storage"
 	"log"
 	"os"
 
@@ -13,6 +14,7 @@ import (
 func init() {
 	database.LoadInitializers()
 	database.ConnectToDb()
+	storage.Init()
 }
 
 func main() {
@@ -0,0 +1,140 @@
+package events
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+	"user_service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxNotifyPayload matches chat_service's broker: larger events are
+	// stored as a models.BrokerPayload and only its id is notified.
+	maxNotifyPayload = 7900
+
+	// spillRetention is how long a spilled event stays for listeners to load.
+	spillRetention = time.Minute
+)
+
+type ContentPart struct {
+	ContentType  models.ContentType `json:"content_type"`
+	Content      string             `json:"content"`
+	AttachmentID uint               `json:"attachment_id,omitempty"`
+	Preview      *LinkPreview       `json:"preview,omitempty"`
+}
+
+type LinkPreview struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// Envelope is the subset of chat_service's WebSocket envelope that
+// user_service emits when a REST call changes a conversation.
+type Envelope struct {
+	Version        int           `json:"v"`
+	Type           string        `json:"type"`
+	ConversationID uint          `json:"conversation_id,omitempty"`
+	MessageID      uint          `json:"message_id,omitempty"`
+	SenderID       uint          `json:"sender_id,omitempty"`
+	Content        []ContentPart `json:"content,omitempty"`
+	Status         string        `json:"status,omitempty"`
+	Name           string        `json:"name,omitempty"`
+	MemberID       uint          `json:"member_id,omitempty"`
+	ServerTime     *time.Time    `json:"server_time,omitempty"`
+	EditedAt       *time.Time    `json:"edited_at,omitempty"`
+	DeletedAt      *time.Time    `json:"deleted_at,omitempty"`
+}
+
+type message struct {
+	ConversationID uint            `json:"conversation_id,omitempty"`
+	ProfileID      uint            `json:"profile_id,omitempty"`
+	Data           json.RawMessage `json:"data"`
+	SenderID       uint            `json:"sender_id,omitempty"`
+	MemberAdded    uint            `json:"member_added,omitempty"`
+	MemberRemoved  uint            `json:"member_removed,omitempty"`
+	PreviewMessage uint            `json:"preview_message,omitempty"`
+}
+
+type notification struct {
+	Message *message `json:"m,omitempty"`
+	SpillID uint     `json:"spill_id,omitempty"`
+}
+
+// channel is the one every chat_service replica listens on for other
+// services' events, whichever broker its hubs share traffic through.
+func channel() string {
+	if channel := os.Getenv("CHAT_EVENTS_CHANNEL"); channel != "" {
+		return channel
+	}
+	return "chat_service_events"
+}
+
+// PublishToConversation hands env to chat_service, which delivers it to every
+// socket subscribed to the conversation. Run it on the request's transaction
+// so the event is only sent if the change commits.
+func PublishToConversation(tx *gorm.DB, conversationID uint, env Envelope) error {
+	return publish(tx, &message{ConversationID: conversationID}, env)
+}
+
+// PublishMemberAdded is PublishToConversation for a new member: chat_service
+// first subscribes the member's open user-scoped sockets, so they receive env
+// too.
+func PublishMemberAdded(tx *gorm.DB, conversationID, memberID uint, env Envelope) error {
+	return publish(tx, &message{ConversationID: conversationID, MemberAdded: memberID}, env)
+}
+
+// PublishMemberRemoved is PublishToConversation for a member who left or was
+// removed: chat_service delivers env and then unsubscribes every socket of
+// that member from the conversation.
+func PublishMemberRemoved(tx *gorm.DB, conversationID, memberID uint, env Envelope) error {
+	return publish(tx, &message{ConversationID: conversationID, MemberRemoved: memberID}, env)
+}
+
+// PublishEdit is PublishToConversation for an edited message: chat_service
+// also fetches previews of the links it does not have one for yet.
+func PublishEdit(tx *gorm.DB, conversationID, messageID uint, env Envelope) error {
+	return publish(tx, &message{ConversationID: conversationID, PreviewMessage: messageID}, env)
+}
+
+func publish(tx *gorm.DB, msg *message, env Envelope) error {
+	env.Version = 1
+	if env.ServerTime == nil {
+		now := time.Now()
+		env.ServerTime = &now
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	msg.Data = data
+	msg.SenderID = env.SenderID
+	payload, err := json.Marshal(notification{Message: msg})
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > maxNotifyPayload {
+		raw, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		spill := models.BrokerPayload{Payload: string(raw)}
+		if err := tx.Create(&spill).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().
+			Where("created_at < ?", time.Now().Add(-spillRetention)).
+			Delete(&models.BrokerPayload{}).Error; err != nil {
+			return err
+		}
+		if payload, err = json.Marshal(notification{SpillID: spill.ID}); err != nil {
+			return err
+		}
+	}
+	return tx.Exec("SELECT pg_notify(?, ?)", channel(), string(payload)).Error
+}
@@ -69,9 +69,15 @@ func AcceptFriendRequest(c *gin.Context) {
 		return
 	}
 
+	requesterID, receiverID := friendRequest.RequesterID, friendRequest.ReceiverID
 	conversations := models.Conversations{
-		Profile1ID: friendRequest.RequesterID,
-		Profile2ID: friendRequest.ReceiverID,
+		ConversationType: models.OneToOne,
+		Profile1ID:       &requesterID,
+		Profile2ID:       &receiverID,
+		Members: []models.ConversationMember{
+			{UserID: requesterID},
+			{UserID: receiverID},
+		},
 	}
 
 	conversations.NormalizeProfiles()
@@ -152,7 +158,12 @@ func AcceptFriendRequest(c *gin.Context) {
 
 	conversations.PrivateKey = &keyPairResponse.PrivateKey
 	conversations.PublicKey = &keyPairResponse.PublicKey
-	if err := tx.Save(&conversations).Error; err != nil {
+	// Only the keys changed; saving the whole struct would insert Members
+	// again.
+	if err := tx.Model(&conversations).Updates(map[string]interface{}{
+		"private_key": conversations.PrivateKey,
+		"public_key":  conversations.PublicKey,
+	}).Error; err != nil {
 		tx.Rollback()
 		c.JSON(500, utils.Response{
 			Code:    500,
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"user_service/internal/models"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// currentProfile resolves the caller's profile through auth_service, the
+// same way GetCurrentUser does. When it fails the error response has already
+// been written and ok is false.
+func currentProfile(c *gin.Context, tx *gorm.DB) (profile models.Profile, ok bool) {
+	authHeader := c.Request.Header.Get("Authorization")
+	if authHeader == "" {
+		c.JSON(401, utils.Response{
+			Code:    401,
+			Success: false,
+			Message: "Unauthorized",
+			Error:   "Authorization header is missing",
+		})
+		return profile, false
+	}
+
+	verifyUrl := os.Getenv("VERIFY_TOKEN_CLAIMS")
+	if verifyUrl == "" {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "env is missing",
+			Error:   "VERIFY_TOKEN_CLAIMS is not set in the environment variables",
+		})
+		return profile, false
+	}
+
+	req, err := http.NewRequest("GET", verifyUrl, nil)
+	if err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+		return profile, false
+	}
+	req.Header.Set("Authorization", authHeader)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+		return profile, false
+	}
+	defer resp.Body.Close()
+
+	var verifyResp utils.VerifyResp
+	if err := json.NewDecoder(resp.Body).Decode(&verifyResp); err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to decode response",
+			Error:   err.Error(),
+		})
+		return profile, false
+	}
+	if !verifyResp.Success {
+		c.JSON(401, utils.Response{
+			Code:    401,
+			Success: false,
+			Message: "Unauthorized",
+			Error:   verifyResp.Error,
+		})
+		return profile, false
+	}
+
+	if err := tx.Where("email = ?", verifyResp.User.Email).First(&profile).Error; err != nil {
+		c.JSON(404, utils.Response{
+			Code:    404,
+			Success: false,
+			Message: "Profile not found",
+			Error:   err.Error(),
+		})
+		return profile, false
+	}
+	return profile, true
+}
+
+// participantCondition matches conversations the profile is a member of.
+// One-to-one conversations have a member row for each side, so this covers
+// both kinds.
+const participantCondition = "EXISTS (SELECT 1 FROM conversation_members " +
+	"WHERE conversation_members.conversation_id = conversations.id " +
+	"AND conversation_members.user_id = ? AND conversation_members.deleted_at IS NULL)"
+
+func isParticipant(tx *gorm.DB, conversationID, profileID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.Conversations{}).
+		Where("id = ?", conversationID).
+		Where(participantCondition, profileID).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+var (
+	errMessageNotFound = errors.New("message not found")
+	errNotSender       = errors.New("only the sender can change a message")
+	errRetracted       = errors.New("message was deleted")
+	errSystemMessage   = errors.New("system messages cannot be changed")
+	errNotParticipant  = errors.New("not a participant of this conversation")
+)
+
+// loadOwnMessage fetches a live message and checks that profileID sent it and
+// still takes part in its conversation. The row stays locked until tx ends,
+// so concurrent changes of the message apply one after the other.
+func loadOwnMessage(tx *gorm.DB, messageID, profileID uint) (models.Messages, error) {
+	var message models.Messages
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Content").
+		Where("id = ?", messageID).
+		First(&message).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return message, errMessageNotFound
+		}
+		return message, err
+	}
+	if message.IsSystem {
+		return message, errSystemMessage
+	}
+	if message.SenderID != profileID {
+		return message, errNotSender
+	}
+	if message.RetractedAt != nil {
+		return message, errRetracted
+	}
+	participant, err := isParticipant(tx, message.ConversationID, profileID)
+	if err != nil {
+		return message, err
+	}
+	if !participant {
+		return message, errNotParticipant
+	}
+	return message, nil
+}
+
+// messageChangeStatus maps loadOwnMessage errors to a response code.
+func messageChangeStatus(err error) int {
+	switch {
+	case errors.Is(err, errMessageNotFound):
+		return 404
+	case errors.Is(err, errNotSender), errors.Is(err, errRetracted), errors.Is(err, errSystemMessage),
+		errors.Is(err, errNotParticipant):
+		return 403
+	default:
+		return 500
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"user_service/internal/database"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type addGroupMembersRequest struct {
+	ConversationID uint   `json:"conversation_id" binding:"required"`
+	MemberIDs      []uint `json:"member_ids" binding:"required,min=1"`
+}
+
+type addGroupMembersResponse struct {
+	utils.Response
+	Added []uint `json:"added"`
+}
+
+// AddGroupMembers lets an admin bring their friends into the group. Profiles
+// that are already members are skipped.
+func AddGroupMembers(c *gin.Context) {
+	var req addGroupMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	group, _, err := loadGroupAsAdmin(tx, req.ConversationID, profile.ID)
+	if err == nil {
+		err = checkFriends(tx, profile.ID, req.MemberIDs)
+	}
+	var added []uint
+	if err == nil {
+		added, err = addGroupMembers(tx, group, profile.ID, req.MemberIDs)
+	}
+	if err != nil {
+		tx.Rollback()
+		status := groupChangeStatus(err)
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Cannot add group members",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to commit transaction",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, addGroupMembersResponse{
+		Response: utils.Response{
+			Code:    200,
+			Success: true,
+			Message: "Group members added",
+		},
+		Added: added,
+	})
+}
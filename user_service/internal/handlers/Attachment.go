@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"user_service/internal/events"
+	"user_service/internal/models"
+	"user_service/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxAttachmentChunk  = 5 << 20
+	attachmentUploadTTL = 24 * time.Hour
+	maxFileNameLength   = 255
+)
+
+// attachmentSizeLimits is the largest upload accepted for each content type.
+var attachmentSizeLimits = map[models.ContentType]int64{
+	models.ContentTypeImage: 10 << 20,
+	models.ContentTypeAudio: 25 << 20,
+	models.ContentTypeFile:  50 << 20,
+	models.ContentTypeVideo: 100 << 20,
+}
+
+// attachmentMimeTypes lists the MIME types that can be uploaded and the
+// content type a message part referencing them gets.
+var attachmentMimeTypes = map[string]models.ContentType{
+	"image/png":       models.ContentTypeImage,
+	"image/jpeg":      models.ContentTypeImage,
+	"image/gif":       models.ContentTypeImage,
+	"image/webp":      models.ContentTypeImage,
+	"audio/mpeg":      models.ContentTypeAudio,
+	"audio/ogg":       models.ContentTypeAudio,
+	"audio/wav":       models.ContentTypeAudio,
+	"audio/wave":      models.ContentTypeAudio,
+	"audio/webm":      models.ContentTypeAudio,
+	"video/mp4":       models.ContentTypeVideo,
+	"video/webm":      models.ContentTypeVideo,
+	"application/pdf": models.ContentTypeFile,
+	"application/zip": models.ContentTypeFile,
+	"text/plain":      models.ContentTypeFile,
+}
+
+var errUnsupportedMimeType = errors.New("file content does not match an allowed type")
+
+func baseMimeType(value string) string {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(mediaType)
+}
+
+// sniffMimeType checks the first bytes of an upload and returns the MIME
+// type to store. Bytes that sniff as an allowed type must match the upload's
+// content type and are stored under the sniffed type, whatever the client
+// declared. Images must sniff as an allowed image; other uploads that match
+// no allowed type are stored as application/octet-stream.
+func sniffMimeType(head []byte, contentType models.ContentType) (string, error) {
+	sniffed := baseMimeType(http.DetectContentType(head))
+	switch {
+	case sniffed == "application/ogg":
+		sniffed = "audio/ogg"
+	case sniffed == "video/webm" && contentType == models.ContentTypeAudio:
+		// WebM audio has the same signature as WebM video.
+		sniffed = "audio/webm"
+	}
+
+	if sniffedType, ok := attachmentMimeTypes[sniffed]; ok {
+		if sniffedType != contentType {
+			return "", errUnsupportedMimeType
+		}
+		return sniffed, nil
+	}
+	if contentType == models.ContentTypeImage {
+		return "", errUnsupportedMimeType
+	}
+	return "application/octet-stream", nil
+}
+
+// blobMatchesUpload sniffs a committed blob again. The first chunk is sniffed
+// before it is recorded, but a chunk racing it for the same offset may have
+// left other bytes behind.
+func blobMatchesUpload(blob storage.Blob, upload models.AttachmentUpload) (bool, error) {
+	r, err := storage.Blobs.Open(blob.Key)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, err
+	}
+	mimeType, err := sniffMimeType(head[:n], upload.ContentType)
+	return err == nil && mimeType == upload.MimeType, nil
+}
+
+// cleanFileName keeps the last path element of a client supplied name,
+// without control characters, so it is safe in a Content-Disposition header.
+func cleanFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "." || name == "/" {
+		return ""
+	}
+	if runes := []rune(name); len(runes) > maxFileNameLength {
+		name = string(runes[:maxFileNameLength])
+	}
+	return name
+}
+
+// uploadKey names the staged copy of an upload in storage.
+func uploadKey(upload models.AttachmentUpload) string {
+	return strconv.FormatUint(uint64(upload.ID), 10)
+}
+
+var errInvalidAttachment = errors.New("attachment does not belong to this conversation or sender, or does not match the content type")
+
+// checkAttachments verifies that every attachment referenced by parts was
+// uploaded by profileID to the conversation, with the part's content type.
+func checkAttachments(tx *gorm.DB, parts []events.ContentPart, conversationID, profileID uint) error {
+	for _, part := range parts {
+		if part.AttachmentID == 0 {
+			continue
+		}
+		var count int64
+		if err := tx.Model(&models.Attachment{}).
+			Where("id = ? AND conversation_id = ? AND uploader_id = ? AND content_type = ?",
+				part.AttachmentID, conversationID, profileID, part.ContentType).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errInvalidAttachment
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"testing"
+	"user_service/internal/models"
+)
+
+func TestSniffMimeType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	pdf := []byte("%PDF-1.7\n")
+	webm := []byte("\x1a\x45\xdf\xa3\x01\x00\x00\x00")
+	html := []byte("<html><script>alert(1)</script></html>")
+	random := []byte{0x00, 0x01, 0x02, 0x03, 0xfe, 0xff}
+
+	tests := []struct {
+		name        string
+		head        []byte
+		contentType models.ContentType
+		want        string
+		wantErr     bool
+	}{
+		{"image", png, models.ContentTypeImage, "image/png", false},
+		{"pdf file", pdf, models.ContentTypeFile, "application/pdf", false},
+		{"webm audio", webm, models.ContentTypeAudio, "audio/webm", false},
+		{"webm video", webm, models.ContentTypeVideo, "video/webm", false},
+		{"image declared as file", png, models.ContentTypeFile, "", true},
+		{"pdf declared as image", pdf, models.ContentTypeImage, "", true},
+		{"unknown image", random, models.ContentTypeImage, "", true},
+		{"unknown video", random, models.ContentTypeVideo, "application/octet-stream", false},
+		{"unknown file", random, models.ContentTypeFile, "application/octet-stream", false},
+		{"html file", html, models.ContentTypeFile, "application/octet-stream", false},
+	}
+	for _, tt := range tests {
+		got, err := sniffMimeType(tt.head, tt.contentType)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: sniffMimeType = %q, %v; want %q, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"user_service/internal/database"
+	"user_service/internal/models"
+	"user_service/internal/storage"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type completeAttachmentUploadRequest struct {
+	UploadID uint `json:"upload_id" binding:"required"`
+}
+
+type completeAttachmentUploadResponse struct {
+	utils.Response
+	Data models.Attachment `json:"data"`
+}
+
+// CompleteAttachmentUpload turns a fully received upload into an attachment
+// that message content parts of the conversation can reference.
+func CompleteAttachmentUpload(c *gin.Context) {
+	var req completeAttachmentUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	var upload models.AttachmentUpload
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND profile_id = ?", req.UploadID, profile.ID).
+		First(&upload).Error; err != nil {
+		tx.Rollback()
+		status := 500
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = 404
+		}
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Upload not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if upload.Received != upload.Size {
+		tx.Rollback()
+		c.JSON(409, utils.Response{
+			Code:    409,
+			Success: false,
+			Message: "Upload is incomplete",
+			Error:   "not every chunk was received",
+		})
+		return
+	}
+
+	blob, err := storage.Blobs.Commit(uploadKey(upload))
+	if err != nil {
+		tx.Rollback()
+		status := 500
+		if errors.Is(err, storage.ErrNotFound) {
+			status = 410
+		}
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Failed to store attachment",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	matches, err := blobMatchesUpload(blob, upload)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to check attachment",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if !matches {
+		tx.Rollback()
+		c.JSON(415, utils.Response{
+			Code:    415,
+			Success: false,
+			Message: "Unsupported file type",
+			Error:   errUnsupportedMimeType.Error(),
+		})
+		return
+	}
+
+	attachment := models.Attachment{
+		ConversationID: upload.ConversationID,
+		UploaderID:     profile.ID,
+		ContentType:    upload.ContentType,
+		MimeType:       upload.MimeType,
+		FileName:       upload.FileName,
+		Size:           blob.Size,
+		StorageKey:     blob.Key,
+	}
+	if err := tx.Create(&attachment).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to create attachment",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := tx.Unscoped().Delete(&upload).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to finish upload",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to commit transaction",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(201, completeAttachmentUploadResponse{
+		Response: utils.Response{
+			Code:    201,
+			Success: true,
+			Message: "Attachment uploaded",
+		},
+		Data: attachment,
+	})
+}
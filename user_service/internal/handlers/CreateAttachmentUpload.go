@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"log"
+	"time"
+	"user_service/internal/database"
+	"user_service/internal/models"
+	"user_service/internal/storage"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createAttachmentUploadRequest struct {
+	ConversationID uint   `json:"conversation_id" binding:"required"`
+	FileName       string `json:"file_name" binding:"required"`
+	MimeType       string `json:"mime_type" binding:"required"`
+	Size           int64  `json:"size" binding:"required,gt=0"`
+}
+
+type attachmentUploadData struct {
+	UploadID  uint      `json:"upload_id"`
+	Received  int64     `json:"received"`
+	Size      int64     `json:"size"`
+	ChunkSize int64     `json:"chunk_size"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type attachmentUploadResponse struct {
+	utils.Response
+	Data attachmentUploadData `json:"data"`
+}
+
+// CreateAttachmentUpload starts a chunked upload to a conversation. The
+// declared type and size are checked here; the bytes are checked again as
+// they arrive.
+func CreateAttachmentUpload(c *gin.Context) {
+	var req createAttachmentUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	mimeType := baseMimeType(req.MimeType)
+	contentType, ok := attachmentMimeTypes[mimeType]
+	if !ok {
+		c.JSON(415, utils.Response{
+			Code:    415,
+			Success: false,
+			Message: "Unsupported file type",
+			Error:   "mime_type is not allowed",
+		})
+		return
+	}
+	if req.Size > attachmentSizeLimits[contentType] {
+		c.JSON(413, utils.Response{
+			Code:    413,
+			Success: false,
+			Message: "File too large",
+			Error:   "size exceeds the limit for this file type",
+		})
+		return
+	}
+	fileName := cleanFileName(req.FileName)
+	if fileName == "" {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   "file_name is empty",
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	participant, err := isParticipant(tx, req.ConversationID, profile.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to check conversation access",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if !participant {
+		tx.Rollback()
+		c.JSON(403, utils.Response{
+			Code:    403,
+			Success: false,
+			Message: "Forbidden",
+			Error:   "Not a participant of this conversation",
+		})
+		return
+	}
+
+	// Abandoned uploads are cleaned up here rather than by a background job.
+	var expired []models.AttachmentUpload
+	if err := tx.Where("expires_at < ?", time.Now()).Find(&expired).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to clean up expired uploads",
+			Error:   err.Error(),
+		})
+		return
+	}
+	for _, upload := range expired {
+		if err := storage.Blobs.Discard(uploadKey(upload)); err != nil {
+			log.Printf("failed to discard expired upload %d: %v", upload.ID, err)
+			continue
+		}
+		if err := tx.Unscoped().Delete(&upload).Error; err != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Failed to clean up expired uploads",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	upload := models.AttachmentUpload{
+		ConversationID: req.ConversationID,
+		ProfileID:      profile.ID,
+		ContentType:    contentType,
+		MimeType:       mimeType,
+		FileName:       fileName,
+		Size:           req.Size,
+		ExpiresAt:      time.Now().Add(attachmentUploadTTL),
+	}
+	if err := tx.Create(&upload).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to start upload",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to commit transaction",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(201, attachmentUploadResponse{
+		Response: utils.Response{
+			Code:    201,
+			Success: true,
+			Message: "Upload started",
+		},
+		Data: attachmentUploadData{
+			UploadID:  upload.ID,
+			Size:      upload.Size,
+			ChunkSize: maxAttachmentChunk,
+			ExpiresAt: upload.ExpiresAt,
+		},
+	})
+}
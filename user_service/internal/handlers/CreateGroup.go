@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"user_service/internal/database"
+	"user_service/internal/models"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createGroupRequest struct {
+	Name      string `json:"name" binding:"required"`
+	MemberIDs []uint `json:"member_ids" binding:"required,min=1"`
+}
+
+type groupResponse struct {
+	utils.Response
+	Data models.Conversations `json:"data"`
+}
+
+// CreateGroup starts a named group owned by the caller with some of their
+// friends. Groups get no key pair; only one-to-one conversations do.
+func CreateGroup(c *gin.Context) {
+	var req createGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+	name, ok := cleanGroupName(req.Name)
+	if !ok {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   "name must be 1 to 100 characters",
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	if err := checkFriends(tx, profile.ID, req.MemberIDs); err != nil {
+		tx.Rollback()
+		status := groupChangeStatus(err)
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Cannot create group",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	group := models.Conversations{
+		ConversationType: models.Group,
+		Name:             &name,
+	}
+	if err := tx.Create(&group).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to create group",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	err := joinGroup(tx, group, profile.ID, profile.ID, models.RoleOwner)
+	if err == nil {
+		err = postSystemMessage(tx, group.ID, systemEvent{
+			Action:  actionGroupCreated,
+			ActorID: profile.ID,
+			Name:    name,
+		})
+	}
+	if err == nil {
+		_, err = addGroupMembers(tx, group, profile.ID, req.MemberIDs)
+	}
+	if err != nil {
+		tx.Rollback()
+		status := groupChangeStatus(err)
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Failed to add group members",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Preload("Members.User").Where("id = ?", group.ID).First(&group).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to load group",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to commit transaction",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(201, groupResponse{
+		Response: utils.Response{
+			Code:    201,
+			Success: true,
+			Message: "Group created",
+		},
+		Data: group,
+	})
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"time"
+	"user_service/internal/database"
+	"user_service/internal/events"
+	"user_service/internal/models"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type deleteMessageRequest struct {
+	MessageID uint `form:"message_id" binding:"required"`
+}
+
+// DeleteMessage turns the caller's message into a tombstone: the row stays
+// in the conversation while its content, edit history and reactions are
+// removed.
+func DeleteMessage(c *gin.Context) {
+	var req deleteMessageRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	message, err := loadOwnMessage(tx, req.MessageID, profile.ID)
+	if err != nil {
+		tx.Rollback()
+		status := messageChangeStatus(err)
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Cannot delete message",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Unscoped().Where("message_id = ?", message.ID).Delete(&models.MessageContent{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to delete message content",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := tx.Unscoped().Where("message_id = ?", message.ID).Delete(&models.MessageEdit{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to delete edit history",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := tx.Unscoped().Where("message_id = ?", message.ID).Delete(&models.MessageReaction{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to delete reactions",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	now := time.Now()
+	if err := tx.Model(&message).Update("retracted_at", now).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to delete message",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := events.PublishToConversation(tx, message.ConversationID, events.Envelope{
+		Type:           "delete",
+		ConversationID: message.ConversationID,
+		MessageID:      message.ID,
+		SenderID:       profile.ID,
+		ServerTime:     &message.CreatedAt,
+		DeletedAt:      &now,
+	}); err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to publish deletion",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to commit transaction",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, utils.Response{
+		Code:    200,
+		Success: true,
+		Message: "Message deleted",
+		Error:   nil,
+	})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"user_service/internal/database"
+	"user_service/internal/models"
+	"user_service/internal/storage"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type downloadAttachmentRequest struct {
+	AttachmentID uint `form:"attachment_id" binding:"required"`
+}
+
+// DownloadAttachment streams an attachment to a participant of the
+// conversation it was uploaded to. Range requests are supported.
+func DownloadAttachment(c *gin.Context) {
+	var req downloadAttachmentRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	var attachment models.Attachment
+	if err := tx.Where("id = ?", req.AttachmentID).First(&attachment).Error; err != nil {
+		tx.Rollback()
+		status := 500
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = 404
+		}
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Attachment not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	participant, err := isParticipant(tx, attachment.ConversationID, profile.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to check conversation access",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if !participant {
+		tx.Rollback()
+		c.JSON(403, utils.Response{
+			Code:    403,
+			Success: false,
+			Message: "Forbidden",
+			Error:   "Not a participant of this conversation",
+		})
+		return
+	}
+	tx.Commit()
+
+	blob, err := storage.Blobs.Open(attachment.StorageKey)
+	if err != nil {
+		status := 500
+		if errors.Is(err, storage.ErrNotFound) {
+			status = 404
+		} else {
+			log.Printf("failed to open attachment %d: %v", attachment.ID, err)
+		}
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Attachment content not available",
+			Error:   err.Error(),
+		})
+		return
+	}
+	defer blob.Close()
+
+	disposition := "inline"
+	if attachment.ContentType == models.ContentTypeFile {
+		disposition = "attachment"
+	}
+	header := c.Writer.Header()
+	header.Set("Content-Type", attachment.MimeType)
+	if value := mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}); value != "" {
+		header.Set("Content-Disposition", value)
+	} else {
+		header.Set("Content-Disposition", disposition)
+	}
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Content-Security-Policy", "sandbox")
+	header.Set("Cache-Control", "private, max-age=86400")
+	header.Set("ETag", `"`+attachment.StorageKey+`"`)
+
+	http.ServeContent(c.Writer, c.Request, "", attachment.CreatedAt, blob)
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"user_service/internal/database"
+	"user_service/internal/events"
+	"user_service/internal/models"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxContentParts  = 10
+	maxContentLength = 4000
+)
+
+type editMessageRequest struct {
+	MessageID uint                 `json:"message_id" binding:"required"`
+	Content   []events.ContentPart `json:"content" binding:"required"`
+}
+
+type editMessageResponse struct {
+	utils.Response
+	Data models.Messages `json:"data"`
+}
+
+var editableContentTypes = map[models.ContentType]bool{
+	models.ContentTypeText:  true,
+	models.ContentTypeLink:  true,
+	models.ContentTypeImage: true,
+	models.ContentTypeFile:  true,
+	models.ContentTypeVideo: true,
+	models.ContentTypeAudio: true,
+}
+
+// validateContent applies the limits chat_service enforces on message
+// frames.
+func validateContent(parts []events.ContentPart) string {
+	if len(parts) == 0 {
+		return "message has no content"
+	}
+	if len(parts) > maxContentParts {
+		return "message has too many content parts"
+	}
+	for _, part := range parts {
+		if !editableContentTypes[part.ContentType] {
+			return "unsupported content_type"
+		}
+		_, isAttachment := attachmentSizeLimits[part.ContentType]
+		if isAttachment != (part.AttachmentID != 0) {
+			return "attachment_id is required for attachment parts and only allowed on them"
+		}
+		if !isAttachment && strings.TrimSpace(part.Content) == "" {
+			return "content part is empty"
+		}
+		if !utf8.ValidString(part.Content) || utf8.RuneCountInString(part.Content) > maxContentLength {
+			return "content part is too long or not valid UTF-8"
+		}
+	}
+	return ""
+}
+
+// contentParts turns stored content back into the parts of an envelope.
+func contentParts(content []models.MessageContent) []events.ContentPart {
+	parts := make([]events.ContentPart, 0, len(content))
+	for _, row := range content {
+		part := events.ContentPart{ContentType: row.ContentType, Content: row.Content}
+		if row.AttachmentID != nil {
+			part.AttachmentID = *row.AttachmentID
+		}
+		if row.LinkPreview != nil && !row.LinkPreview.Failed {
+			part.Preview = &events.LinkPreview{
+				Title:       row.LinkPreview.Title,
+				Description: row.LinkPreview.Description,
+				ImageURL:    row.LinkPreview.ImageURL,
+				SiteName:    row.LinkPreview.SiteName,
+			}
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// keepLinkPreviews gives the link parts of content the preview the link part
+// with the same URL had in previous, so an edit that keeps a link keeps its
+// preview. chat_service fetches the others; its
+// edit frames keep previews the same way.
+func keepLinkPreviews(previous, content []models.MessageContent) {
+	previewIDs := make(map[string]uint)
+	for _, row := range previous {
+		if row.ContentType == models.ContentTypeLink && row.LinkPreviewID != nil {
+			previewIDs[strings.TrimSpace(row.Content)] = *row.LinkPreviewID
+		}
+	}
+	for i := range content {
+		if content[i].ContentType != models.ContentTypeLink {
+			continue
+		}
+		if previewID, ok := previewIDs[strings.TrimSpace(content[i].Content)]; ok {
+			content[i].LinkPreviewID = &previewID
+		}
+	}
+}
+
+func EditMessage(c *gin.Context) {
+	var req editMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if msg := validateContent(req.Content); msg != "" {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid content",
+			Error:   msg,
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	message, err := loadOwnMessage(tx, req.MessageID, profile.ID)
+	if err != nil {
+		tx.Rollback()
+		status := messageChangeStatus(err)
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Cannot edit message",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := checkAttachments(tx, req.Content, message.ConversationID, profile.ID); err != nil {
+		tx.Rollback()
+		status := 500
+		if errors.Is(err, errInvalidAttachment) {
+			status = 400
+		}
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Invalid content",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	previousJSON, err := json.Marshal(contentParts(message.Content))
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to record edit history",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := tx.Create(&models.MessageEdit{
+		MessageID:       message.ID,
+		EditorID:        profile.ID,
+		PreviousContent: string(previousJSON),
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to record edit history",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Unscoped().Where("message_id = ?", message.ID).Delete(&models.MessageContent{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to replace message content",
+			Error:   err.Error(),
+		})
+		return
+	}
+	content := make([]models.MessageContent, 0, len(req.Content))
+	for _, part := range req.Content {
+		row := models.MessageContent{
+			MessageID:   message.ID,
+			ContentType: part.ContentType,
+			Content:     part.Content,
+		}
+		if part.AttachmentID != 0 {
+			attachmentID := part.AttachmentID
+			row.AttachmentID = &attachmentID
+		}
+		content = append(content, row)
+	}
+	keepLinkPreviews(message.Content, content)
+	if err := tx.Create(&content).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to replace message content",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := tx.Preload("LinkPreview").Where("message_id = ?", message.ID).Order("id").Find(&content).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to load link previews",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	now := time.Now()
+	if err := tx.Model(&message).Update("edited_at", now).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to update message",
+			Error:   err.Error(),
+		})
+		return
+	}
+	message.Content = content
+	message.EditedAt = &now
+
+	if err := events.PublishEdit(tx, message.ConversationID, message.ID, events.Envelope{
+		Type:           "edit",
+		ConversationID: message.ConversationID,
+		MessageID:      message.ID,
+		SenderID:       profile.ID,
+		Content:        contentParts(content),
+		ServerTime:     &message.CreatedAt,
+		EditedAt:       &now,
+	}); err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to publish edit",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to commit transaction",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, editMessageResponse{
+		Response: utils.Response{
+			Code:    200,
+			Success: true,
+			Message: "Message edited",
+		},
+		Data: message,
+	})
+}
@@ -53,10 +53,13 @@ func GetFriends(c *gin.Context) {
 
 	var friendIDs []uint
 	for _, conv := range conversations {
-		if conv.Profile1ID == req.ID {
-			friendIDs = append(friendIDs, conv.Profile2ID)
+		if conv.Profile1ID == nil || conv.Profile2ID == nil {
+			continue
+		}
+		if *conv.Profile1ID == req.ID {
+			friendIDs = append(friendIDs, *conv.Profile2ID)
 		} else {
-			friendIDs = append(friendIDs, conv.Profile1ID)
+			friendIDs = append(friendIDs, *conv.Profile1ID)
 		}
 	}
 
@@ -1,31 +1,210 @@
 package handlers
 
 import (
+	"slices"
+	"time"
 	"user_service/internal/database"
 	"user_service/internal/models"
 	"user_service/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+type threadSummary struct {
+	ReplyCount        int64     `json:"reply_count"`
+	LastReplyID       uint      `json:"last_reply_id"`
+	LastReplySenderID uint      `json:"last_reply_sender_id"`
+	LastReplyAt       time.Time `json:"last_reply_at"`
+}
+
+type reactionCount struct {
+	Emoji string `json:"emoji"`
+	Count int64  `json:"count"`
+}
+
+// messageView is a message as the client renders it, with the summary of
+// the thread it starts, if any, and how often each emoji was put on it.
+type messageView struct {
+	models.Messages
+	Thread         *threadSummary  `json:"thread,omitempty"`
+	ReactionCounts []reactionCount `json:"reaction_counts,omitempty"`
+}
+
+const defaultMessagePageSize = 50
+
+// getMessagesRequest pages through a conversation by message id. Without a
+// cursor the newest page is returned; before walks back to older messages
+// and after forward to newer ones.
+type getMessagesRequest struct {
+	ConversationID uint `form:"conversationId" binding:"required"`
+	Before         uint `form:"before"`
+	After          uint `form:"after"`
+	Limit          int  `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// getMessageResponse always lists messages oldest first. NextCursor is the
+// before or after value that fetches the following page.
 type getMessageResponse struct {
-	Code    int               `json:"code"`
-	Message string            `json:"message"`
-	Error   string            `json:"error"`
-	Data    []models.Messages `json:"data"`
+	Code       int           `json:"code"`
+	Message    string        `json:"message"`
+	Error      string        `json:"error"`
+	Data       []messageView `json:"data"`
+	HasMore    bool          `json:"has_more"`
+	NextCursor *uint         `json:"next_cursor"`
 }
 
-func GetMessages(c *gin.Context) {
-	conversationsID := c.Query("conversationId")
+// threadSummaries returns the reply count and last reply of every message in
+// messages that has replies.
+func threadSummaries(tx *gorm.DB, messages []models.Messages) (map[uint]*threadSummary, error) {
+	summaries := make(map[uint]*threadSummary)
+	if len(messages) == 0 {
+		return summaries, nil
+	}
+
+	ids := make([]uint, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+
+	var counts []struct {
+		ParentID    uint
+		ReplyCount  int64
+		LastReplyID uint
+	}
+	if err := tx.Model(&models.Messages{}).
+		Select("parent_id, COUNT(*) AS reply_count, MAX(id) AS last_reply_id").
+		Where("parent_id IN ?", ids).
+		Group("parent_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	if len(counts) == 0 {
+		return summaries, nil
+	}
+
+	lastReplyIDs := make([]uint, 0, len(counts))
+	for _, count := range counts {
+		lastReplyIDs = append(lastReplyIDs, count.LastReplyID)
+	}
+	var lastReplies []models.Messages
+	if err := tx.Select("id", "sender_id", "created_at").Where("id IN ?", lastReplyIDs).Find(&lastReplies).Error; err != nil {
+		return nil, err
+	}
+	lastReplyByID := make(map[uint]models.Messages, len(lastReplies))
+	for _, reply := range lastReplies {
+		lastReplyByID[reply.ID] = reply
+	}
+
+	for _, count := range counts {
+		reply := lastReplyByID[count.LastReplyID]
+		summaries[count.ParentID] = &threadSummary{
+			ReplyCount:        count.ReplyCount,
+			LastReplyID:       count.LastReplyID,
+			LastReplySenderID: reply.SenderID,
+			LastReplyAt:       reply.CreatedAt,
+		}
+	}
+	return summaries, nil
+}
+
+// reactionCounts groups the reactions of messages by emoji, most used first.
+func reactionCounts(tx *gorm.DB, messages []models.Messages) (map[uint][]reactionCount, error) {
+	counts := make(map[uint][]reactionCount)
+	if len(messages) == 0 {
+		return counts, nil
+	}
+
+	ids := make([]uint, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+
+	var rows []struct {
+		MessageID uint
+		Emoji     string
+		Count     int64
+	}
+	if err := tx.Model(&models.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count").
+		Where("message_id IN ?", ids).
+		Group("message_id, emoji").
+		Order("message_id, count DESC, MIN(created_at)").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.MessageID] = append(counts[row.MessageID], reactionCount{Emoji: row.Emoji, Count: row.Count})
+	}
+	return counts, nil
+}
+
+func messageViews(tx *gorm.DB, messages []models.Messages) ([]messageView, error) {
+	summaries, err := threadSummaries(tx, messages)
+	if err != nil {
+		return nil, err
+	}
+	reactions, err := reactionCounts(tx, messages)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]messageView, 0, len(messages))
+	for _, message := range messages {
+		views = append(views, messageView{
+			Messages:       message,
+			Thread:         summaries[message.ID],
+			ReactionCounts: reactions[message.ID],
+		})
+	}
+	return views, nil
+}
+
+// pageMessages turns the rows of a page query, fetched newest first unless
+// forward, into a page: oldest first, without the extra row that tells
+// whether another page follows. The cursor continues in the direction of
+// the request: older messages for the initial view and before, newer ones
+// for after.
+func pageMessages(messages []models.Messages, limit int, forward bool) ([]models.Messages, bool, *uint) {
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	if !forward {
+		slices.Reverse(messages)
+	}
 
-	if conversationsID == "" {
+	var nextCursor *uint
+	if hasMore {
+		cursor := messages[0].ID
+		if forward {
+			cursor = messages[len(messages)-1].ID
+		}
+		nextCursor = &cursor
+	}
+	return messages, hasMore, nextCursor
+}
+
+func GetMessages(c *gin.Context) {
+	var req getMessagesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
 		c.JSON(400, utils.Response{
 			Code:    400,
 			Message: "Bad Request",
-			Error:   "Missing conversationsId",
+			Error:   err.Error(),
 		})
 		return
 	}
+	if req.Before != 0 && req.After != 0 {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Message: "Bad Request",
+			Error:   "before and after cannot be combined",
+		})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultMessagePageSize
+	}
 
 	tx := database.DB.Begin()
 	defer func() {
@@ -39,11 +218,59 @@ func GetMessages(c *gin.Context) {
 		}
 	}()
 
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+	participant, err := isParticipant(tx, req.ConversationID, profile.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if !participant {
+		tx.Rollback()
+		c.JSON(403, utils.Response{
+			Code:    403,
+			Message: "Forbidden",
+			Error:   "Not a member of this conversation",
+		})
+		return
+	}
+
+	// One extra row tells whether another page follows.
+	query := tx.Preload("Content.Attachment").Preload("Content.LinkPreview").
+		Where("conversation_id = ?", req.ConversationID).
+		Limit(req.Limit + 1)
+	if req.After != 0 {
+		query = query.Where("id > ?", req.After).Order("id ASC")
+	} else {
+		if req.Before != 0 {
+			query = query.Where("id < ?", req.Before)
+		}
+		query = query.Order("id DESC")
+	}
+
 	var messages []models.Messages
-	if err := tx.Preload("Content").
-		Where("conversation_id = ?", conversationsID).
-		Order("created_at ASC").
-		Find(&messages).Error; err != nil {
+	if err := query.Find(&messages).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Message: "Internal Server Error",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	messages, hasMore, nextCursor := pageMessages(messages, req.Limit, req.After != 0)
+
+	views, err := messageViews(tx, messages)
+	if err != nil {
 		tx.Rollback()
 		c.JSON(500, utils.Response{
 			Code:    500,
@@ -55,8 +282,10 @@ func GetMessages(c *gin.Context) {
 
 	tx.Commit()
 	c.JSON(200, getMessageResponse{
-		Code:    200,
-		Message: "Messages retrieved successfully",
-		Data:    messages,
+		Code:       200,
+		Message:    "Messages retrieved successfully",
+		Data:       views,
+		HasMore:    hasMore,
+		NextCursor: nextCursor,
 	})
 }
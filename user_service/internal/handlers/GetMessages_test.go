@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+	"user_service/internal/database"
+	"user_service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// fetchPage mimics the page query of GetMessages over ids: limit+1 rows,
+// newest first unless after is set.
+func fetchPage(ids []uint, before, after uint, limit int) []models.Messages {
+	var rows []models.Messages
+	for _, id := range ids {
+		if (after != 0 && id > after) || (after == 0 && (before == 0 || id < before)) {
+			rows = append(rows, models.Messages{Model: gorm.Model{ID: id}})
+		}
+	}
+	if after == 0 {
+		slices.Reverse(rows)
+	}
+	if len(rows) > limit+1 {
+		rows = rows[:limit+1]
+	}
+	return rows
+}
+
+func messageIDs(messages []models.Messages) []uint {
+	ids := make([]uint, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+	return ids
+}
+
+func TestPageMessagesCursorRoundTrip(t *testing.T) {
+	ids := []uint{1, 2, 3, 4, 5, 6, 7}
+
+	// Backwards from the newest page.
+	var seen []uint
+	var before uint
+	for pages := 0; ; pages++ {
+		if pages > len(ids) {
+			t.Fatal("paging backwards does not end")
+		}
+		page, hasMore, cursor := pageMessages(fetchPage(ids, before, 0, 3), 3, false)
+		if !slices.IsSorted(messageIDs(page)) {
+			t.Fatalf("page %v is not oldest first", messageIDs(page))
+		}
+		seen = append(messageIDs(page), seen...)
+		if !hasMore {
+			if cursor != nil {
+				t.Errorf("last page has cursor %d", *cursor)
+			}
+			break
+		}
+		before = *cursor
+	}
+	if !slices.Equal(seen, ids) {
+		t.Errorf("paging backwards saw %v, want %v", seen, ids)
+	}
+
+	// Forwards from an older message.
+	seen = nil
+	after := uint(2)
+	for pages := 0; ; pages++ {
+		if pages > len(ids) {
+			t.Fatal("paging forwards does not end")
+		}
+		page, hasMore, cursor := pageMessages(fetchPage(ids, 0, after, 2), 2, true)
+		seen = append(seen, messageIDs(page)...)
+		if !hasMore {
+			break
+		}
+		after = *cursor
+	}
+	if want := ids[2:]; !slices.Equal(seen, want) {
+		t.Errorf("paging forwards saw %v, want %v", seen, want)
+	}
+}
+
+func TestGetMessagesPages(t *testing.T) {
+	setupTestDB(t)
+
+	alice := createTestProfile(t, "alice")
+	bob := createTestProfile(t, "bob")
+	conversation := models.Conversations{
+		ConversationType: models.OneToOne,
+		Profile1ID:       &alice.ID,
+		Profile2ID:       &bob.ID,
+		Members:          []models.ConversationMember{{UserID: alice.ID}, {UserID: bob.ID}},
+	}
+	if err := database.DB.Create(&conversation).Error; err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.Unscoped().Where("conversation_id = ?", conversation.ID).Delete(&models.Messages{})
+		database.DB.Unscoped().Where("conversation_id = ?", conversation.ID).Delete(&models.ConversationMember{})
+		database.DB.Unscoped().Delete(&conversation)
+	})
+
+	var ids []uint
+	for i := 0; i < 5; i++ {
+		message := models.Messages{ConversationID: conversation.ID, SenderID: alice.ID}
+		if err := database.DB.Create(&message).Error; err != nil {
+			t.Fatalf("failed to create message: %v", err)
+		}
+		ids = append(ids, message.ID)
+	}
+
+	authenticateAs(t, bob)
+	var seen []uint
+	target := fmt.Sprintf("/?conversationId=%d&limit=2", conversation.ID)
+	for pages := 0; ; pages++ {
+		if pages > len(ids) {
+			t.Fatal("paging does not end")
+		}
+		w := serve("GET", target, GetMessages)
+		if w.Code != 200 {
+			t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+		}
+		var resp getMessageResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		var page []uint
+		for _, view := range resp.Data {
+			page = append(page, view.ID)
+		}
+		seen = append(page, seen...)
+		if resp.NextCursor == nil {
+			break
+		}
+		target = fmt.Sprintf("/?conversationId=%d&limit=2&before=%d", conversation.ID, *resp.NextCursor)
+	}
+	if !slices.Equal(seen, ids) {
+		t.Errorf("pages saw %v, want %v", seen, ids)
+	}
+}
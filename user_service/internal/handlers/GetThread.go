@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"user_service/internal/database"
+	"user_service/internal/models"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type getThreadRequest struct {
+	MessageID uint `form:"messageId" binding:"required"`
+}
+
+type getThreadResponse struct {
+	utils.Response
+	Root    models.Messages   `json:"root"`
+	Replies []models.Messages `json:"replies"`
+}
+
+// GetThread returns the root of a thread and all of its replies, oldest
+// first. Any message of the thread can be passed as messageId.
+func GetThread(c *gin.Context) {
+	var req getThreadRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Panic Operations",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	var message models.Messages
+	if err := tx.Where("id = ?", req.MessageID).First(&message).Error; err != nil {
+		tx.Rollback()
+		status := 500
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = 404
+		}
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Message not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Replies live in their root's conversation, so the message decides
+	// access to the whole thread.
+	participant, err := isParticipant(tx, message.ConversationID, profile.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to check conversation access",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if !participant {
+		tx.Rollback()
+		c.JSON(403, utils.Response{
+			Code:    403,
+			Success: false,
+			Message: "Forbidden",
+			Error:   "Not a participant of this conversation",
+		})
+		return
+	}
+
+	rootID := message.ID
+	if message.ParentID != nil {
+		rootID = *message.ParentID
+	}
+	var root models.Messages
+	if err := tx.Preload("Content.Attachment").Preload("Content.LinkPreview").
+		Where("id = ?", rootID).
+		First(&root).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to load thread root",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	var replies []models.Messages
+	if err := tx.Preload("Content.Attachment").Preload("Content.LinkPreview").
+		Where("parent_id = ?", root.ID).
+		Order("id ASC").
+		Find(&replies).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to load replies",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tx.Commit()
+	c.JSON(200, getThreadResponse{
+		Response: utils.Response{
+			Code:    200,
+			Success: true,
+			Message: "Thread retrieved successfully",
+		},
+		Root:    root,
+		Replies: replies,
+	})
+}
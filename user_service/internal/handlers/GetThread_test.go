@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"user_service/internal/database"
+	"user_service/internal/models"
+)
+
+func TestGetThread(t *testing.T) {
+	setupTestDB(t)
+
+	alice := createTestProfile(t, "alice")
+	bob := createTestProfile(t, "bob")
+	outsider := createTestProfile(t, "outsider")
+
+	conversation := models.Conversations{
+		ConversationType: models.OneToOne,
+		Profile1ID:       &alice.ID,
+		Profile2ID:       &bob.ID,
+		Members: []models.ConversationMember{
+			{UserID: alice.ID},
+			{UserID: bob.ID},
+		},
+	}
+	if err := database.DB.Create(&conversation).Error; err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+	root := models.Messages{ConversationID: conversation.ID, SenderID: alice.ID}
+	if err := database.DB.Create(&root).Error; err != nil {
+		t.Fatalf("failed to create root: %v", err)
+	}
+	reply := models.Messages{ConversationID: conversation.ID, SenderID: bob.ID, ParentID: &root.ID}
+	if err := database.DB.Create(&reply).Error; err != nil {
+		t.Fatalf("failed to create reply: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.Unscoped().Where("conversation_id = ?", conversation.ID).Delete(&models.Messages{})
+		database.DB.Unscoped().Where("conversation_id = ?", conversation.ID).Delete(&models.ConversationMember{})
+		database.DB.Unscoped().Delete(&conversation)
+	})
+
+	tests := []struct {
+		name      string
+		profile   models.Profile
+		messageID uint
+		status    int
+	}{
+		{"by root", alice, root.ID, 200},
+		{"by reply", alice, reply.ID, 200},
+		{"not a participant", outsider, reply.ID, 403},
+		{"unknown message", alice, reply.ID + 1000000, 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticateAs(t, tt.profile)
+			w := serve("GET", fmt.Sprintf("/?messageId=%d", tt.messageID), GetThread)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != 200 {
+				return
+			}
+
+			var resp getThreadResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Root.ID != root.ID {
+				t.Errorf("root = %d, want %d", resp.Root.ID, root.ID)
+			}
+			if len(resp.Replies) != 1 || resp.Replies[0].ID != reply.ID {
+				t.Errorf("replies = %+v, want only %d", resp.Replies, reply.ID)
+			}
+		})
+	}
+}
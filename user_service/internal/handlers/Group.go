@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode/utf8"
+	"user_service/internal/events"
+	"user_service/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxGroupNameLength = 100
+	maxGroupMembers    = 256
+)
+
+var (
+	errGroupNotFound  = errors.New("group not found")
+	errNotGroupMember = errors.New("not a member of this group")
+	errNotGroupAdmin  = errors.New("only group admins can do this")
+	errNotGroupOwner  = errors.New("only the group owner can do this")
+	errOwnerRole      = errors.New("the owner's membership cannot be changed")
+	errNotFriend      = errors.New("only friends can be added to a group")
+	errGroupFull      = errors.New("group has too many members")
+	errInvalidAvatar  = errors.New("avatar must be an image uploaded to this group")
+)
+
+// groupChangeStatus maps group errors to a response code.
+func groupChangeStatus(err error) int {
+	switch {
+	case errors.Is(err, errGroupNotFound):
+		return 404
+	case errors.Is(err, errNotGroupMember), errors.Is(err, errNotGroupAdmin),
+		errors.Is(err, errNotGroupOwner), errors.Is(err, errOwnerRole):
+		return 403
+	case errors.Is(err, errNotFriend), errors.Is(err, errGroupFull), errors.Is(err, errInvalidAvatar):
+		return 400
+	default:
+		return 500
+	}
+}
+
+// Actions recorded by group system messages.
+const (
+	actionGroupCreated  = "group_created"
+	actionGroupRenamed  = "group_renamed"
+	actionAvatarChanged = "avatar_changed"
+	actionMemberAdded   = "member_added"
+	actionMemberRemoved = "member_removed"
+	actionMemberLeft    = "member_left"
+	actionRoleChanged   = "role_changed"
+)
+
+// systemEvent is the content of a system message: ActorID did Action, to
+// MemberID when the action concerns a member.
+type systemEvent struct {
+	Action   string            `json:"action"`
+	ActorID  uint              `json:"actor_id"`
+	MemberID uint              `json:"member_id,omitempty"`
+	Role     models.MemberRole `json:"role,omitempty"`
+	Name     string            `json:"name,omitempty"`
+	AvatarID uint              `json:"avatar_id,omitempty"`
+}
+
+// postSystemMessage records event in the group's history and sends it to the
+// group like any other message. It is the audit trail of group changes.
+func postSystemMessage(tx *gorm.DB, conversationID uint, event systemEvent) error {
+	content, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	message := models.Messages{
+		ConversationID: conversationID,
+		SenderID:       event.ActorID,
+		IsSystem:       true,
+		Content: []models.MessageContent{
+			{ContentType: models.ContentTypeSystem, Content: string(content)},
+		},
+	}
+	if err := tx.Create(&message).Error; err != nil {
+		return err
+	}
+
+	return events.PublishToConversation(tx, conversationID, events.Envelope{
+		Type:           "message",
+		ConversationID: conversationID,
+		MessageID:      message.ID,
+		SenderID:       event.ActorID,
+		Content:        []events.ContentPart{{ContentType: models.ContentTypeSystem, Content: string(content)}},
+		ServerTime:     &message.CreatedAt,
+	})
+}
+
+func cleanGroupName(name string) (string, bool) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || !utf8.ValidString(name) || utf8.RuneCountInString(name) > maxGroupNameLength {
+		return "", false
+	}
+	return name, true
+}
+
+func isGroupAdmin(member models.ConversationMember) bool {
+	return member.Role == models.RoleOwner || member.Role == models.RoleAdmin
+}
+
+// loadGroup fetches a group conversation and the membership of profileID in
+// it. Every group change starts here, and the group row stays locked until tx
+// ends, so changes of one group apply one after the other and always see
+// current roles.
+func loadGroup(tx *gorm.DB, conversationID, profileID uint) (models.Conversations, models.ConversationMember, error) {
+	var group models.Conversations
+	var member models.ConversationMember
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND conversation_type = ?", conversationID, models.Group).First(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return group, member, errGroupNotFound
+		}
+		return group, member, err
+	}
+	member, err := loadGroupMember(tx, group.ID, profileID)
+	return group, member, err
+}
+
+func loadGroupMember(tx *gorm.DB, conversationID, profileID uint) (models.ConversationMember, error) {
+	var member models.ConversationMember
+	if err := tx.Where("conversation_id = ? AND user_id = ?", conversationID, profileID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return member, errNotGroupMember
+		}
+		return member, err
+	}
+	return member, nil
+}
+
+// loadGroupAsAdmin is loadGroup for changes only admins and the owner may
+// make.
+func loadGroupAsAdmin(tx *gorm.DB, conversationID, profileID uint) (models.Conversations, models.ConversationMember, error) {
+	group, member, err := loadGroup(tx, conversationID, profileID)
+	if err == nil && !isGroupAdmin(member) {
+		err = errNotGroupAdmin
+	}
+	return group, member, err
+}
+
+// checkFriends verifies that profileID has a one-to-one conversation with
+// each of friendIDs, which is what accepting a friend request creates.
+func checkFriends(tx *gorm.DB, profileID uint, friendIDs []uint) error {
+	for _, friendID := range friendIDs {
+		if friendID == profileID {
+			continue
+		}
+		var count int64
+		if err := tx.Model(&models.Conversations{}).
+			Where("conversation_type = ?", models.OneToOne).
+			Where("(profile1_id = ? AND profile2_id = ?) OR (profile1_id = ? AND profile2_id = ?)",
+				profileID, friendID, friendID, profileID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errNotFriend
+		}
+	}
+	return nil
+}
+
+// addGroupMembers makes every profile of memberIDs that is not a member yet
+// join the group as a plain member and records each one. It returns the ids
+// that joined.
+func addGroupMembers(tx *gorm.DB, group models.Conversations, actorID uint, memberIDs []uint) ([]uint, error) {
+	var existing []uint
+	if err := tx.Model(&models.ConversationMember{}).
+		Where("conversation_id = ?", group.ID).
+		Pluck("user_id", &existing).Error; err != nil {
+		return nil, err
+	}
+	isMember := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		isMember[id] = true
+	}
+
+	var added []uint
+	for _, memberID := range memberIDs {
+		if isMember[memberID] {
+			continue
+		}
+		isMember[memberID] = true
+		added = append(added, memberID)
+	}
+	if len(existing)+len(added) > maxGroupMembers {
+		return nil, errGroupFull
+	}
+
+	for _, memberID := range added {
+		if err := joinGroup(tx, group, actorID, memberID, models.RoleMember); err != nil {
+			return nil, err
+		}
+		if err := postSystemMessage(tx, group.ID, systemEvent{
+			Action:   actionMemberAdded,
+			ActorID:  actorID,
+			MemberID: memberID,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return added, nil
+}
+
+// joinGroup stores the membership and subscribes the member's sockets.
+func joinGroup(tx *gorm.DB, group models.Conversations, actorID, memberID uint, role models.MemberRole) error {
+	if err := tx.Create(&models.ConversationMember{
+		ConversationID: group.ID,
+		UserID:         memberID,
+		Role:           role,
+	}).Error; err != nil {
+		return err
+	}
+	return events.PublishMemberAdded(tx, group.ID, memberID, events.Envelope{
+		Type:           "member_added",
+		ConversationID: group.ID,
+		SenderID:       actorID,
+		MemberID:       memberID,
+	})
+}
+
+// removeGroupMember ends a membership, records it as action and unsubscribes
+// the member's sockets from the group.
+func removeGroupMember(tx *gorm.DB, group models.Conversations, actorID uint, member models.ConversationMember, action string) error {
+	if err := tx.Delete(&member).Error; err != nil {
+		return err
+	}
+	if err := postSystemMessage(tx, group.ID, systemEvent{
+		Action:   action,
+		ActorID:  actorID,
+		MemberID: member.UserID,
+	}); err != nil {
+		return err
+	}
+	return events.PublishMemberRemoved(tx, group.ID, member.UserID, events.Envelope{
+		Type:           "member_removed",
+		ConversationID: group.ID,
+		SenderID:       actorID,
+		MemberID:       member.UserID,
+	})
+}
+
+// setMemberRole changes a role and records it.
+func setMemberRole(tx *gorm.DB, group models.Conversations, actorID uint, member models.ConversationMember, role models.MemberRole) error {
+	if err := tx.Model(&member).Update("role", role).Error; err != nil {
+		return err
+	}
+	return postSystemMessage(tx, group.ID, systemEvent{
+		Action:   actionRoleChanged,
+		ActorID:  actorID,
+		MemberID: member.UserID,
+		Role:     role,
+	})
+}
+
+// transferOwnership hands the group to the longest-standing admin, or member
+// if there is no admin, before the owner leaves. A group whose last member
+// leaves keeps no owner.
+func transferOwnership(tx *gorm.DB, group models.Conversations, owner models.ConversationMember) error {
+	var successor models.ConversationMember
+	err := tx.Where("conversation_id = ? AND user_id <> ?", group.ID, owner.UserID).
+		Order(gorm.Expr("CASE WHEN role = ? THEN 0 ELSE 1 END, created_at, id", models.RoleAdmin)).
+		First(&successor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return setMemberRole(tx, group, owner.UserID, successor, models.RoleOwner)
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"user_service/internal/database"
+	"user_service/internal/models"
+)
+
+// createTestGroup stores a group whose members joined in the order given, a
+// minute apart, with the given roles.
+func createTestGroup(t *testing.T, members []models.Profile, roles []models.MemberRole) models.Conversations {
+	t.Helper()
+	name := "group"
+	group := models.Conversations{ConversationType: models.Group, Name: &name}
+	if err := database.DB.Create(&group).Error; err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.Unscoped().Where("message_id IN (SELECT id FROM messages WHERE conversation_id = ?)", group.ID).Delete(&models.MessageContent{})
+		database.DB.Unscoped().Where("conversation_id = ?", group.ID).Delete(&models.Messages{})
+		database.DB.Unscoped().Where("conversation_id = ?", group.ID).Delete(&models.ConversationMember{})
+		database.DB.Unscoped().Delete(&group)
+	})
+
+	joined := time.Now().Add(-time.Hour)
+	for i, profile := range members {
+		member := models.ConversationMember{ConversationID: group.ID, UserID: profile.ID, Role: roles[i]}
+		member.CreatedAt = joined.Add(time.Duration(i) * time.Minute)
+		if err := database.DB.Create(&member).Error; err != nil {
+			t.Fatalf("failed to add member: %v", err)
+		}
+	}
+	return group
+}
+
+func memberRole(t *testing.T, group models.Conversations, profile models.Profile) (models.MemberRole, bool) {
+	t.Helper()
+	var member models.ConversationMember
+	err := database.DB.Where("conversation_id = ? AND user_id = ?", group.ID, profile.ID).Limit(1).Find(&member).Error
+	if err != nil {
+		t.Fatalf("failed to load member: %v", err)
+	}
+	return member.Role, member.ID != 0
+}
+
+func TestGroupRolePermissions(t *testing.T) {
+	setupTestDB(t)
+
+	owner := createTestProfile(t, "owner")
+	admin := createTestProfile(t, "admin")
+	otherAdmin := createTestProfile(t, "other-admin")
+	member := createTestProfile(t, "member")
+	otherMember := createTestProfile(t, "other-member")
+	profiles := []models.Profile{owner, admin, otherAdmin, member, otherMember}
+	roles := []models.MemberRole{models.RoleOwner, models.RoleAdmin, models.RoleAdmin, models.RoleMember, models.RoleMember}
+
+	setRole := func(group models.Conversations, actor, target models.Profile, role models.MemberRole) int {
+		authenticateAs(t, actor)
+		body := fmt.Sprintf(`{"conversation_id":%d,"member_id":%d,"role":%q}`, group.ID, target.ID, role)
+		return serveBody("PUT", "/", strings.NewReader(body), SetGroupMemberRole).Code
+	}
+	remove := func(group models.Conversations, actor, target models.Profile) int {
+		authenticateAs(t, actor)
+		return serve("DELETE", fmt.Sprintf("/?conversation_id=%d&member_id=%d", group.ID, target.ID), RemoveGroupMember).Code
+	}
+
+	tests := []struct {
+		name     string
+		change   func(group models.Conversations) int
+		status   int
+		target   models.Profile
+		wantRole models.MemberRole
+		present  bool
+	}{
+		{"member cannot promote", func(g models.Conversations) int { return setRole(g, member, otherMember, models.RoleAdmin) },
+			403, otherMember, models.RoleMember, true},
+		{"admin promotes", func(g models.Conversations) int { return setRole(g, admin, member, models.RoleAdmin) },
+			200, member, models.RoleAdmin, true},
+		{"admin cannot demote an admin", func(g models.Conversations) int { return setRole(g, admin, otherAdmin, models.RoleMember) },
+			403, otherAdmin, models.RoleAdmin, true},
+		{"owner demotes an admin", func(g models.Conversations) int { return setRole(g, owner, otherAdmin, models.RoleMember) },
+			200, otherAdmin, models.RoleMember, true},
+		{"owner role cannot change", func(g models.Conversations) int { return setRole(g, admin, owner, models.RoleMember) },
+			403, owner, models.RoleOwner, true},
+		{"member cannot remove", func(g models.Conversations) int { return remove(g, member, otherMember) },
+			403, otherMember, models.RoleMember, true},
+		{"admin removes a member", func(g models.Conversations) int { return remove(g, admin, member) },
+			200, member, "", false},
+		{"admin cannot remove an admin", func(g models.Conversations) int { return remove(g, admin, otherAdmin) },
+			403, otherAdmin, models.RoleAdmin, true},
+		{"owner removes an admin", func(g models.Conversations) int { return remove(g, owner, admin) },
+			200, admin, "", false},
+		{"owner cannot be removed", func(g models.Conversations) int { return remove(g, admin, owner) },
+			403, owner, models.RoleOwner, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := createTestGroup(t, profiles, roles)
+			if status := tt.change(group); status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			role, present := memberRole(t, group, tt.target)
+			if present != tt.present || role != tt.wantRole {
+				t.Errorf("target = %q (member %v), want %q (member %v)", role, present, tt.wantRole, tt.present)
+			}
+		})
+	}
+}
+
+func TestLeaveGroupTransfersOwnership(t *testing.T) {
+	setupTestDB(t)
+
+	owner := createTestProfile(t, "owner")
+	member := createTestProfile(t, "member")
+	admin := createTestProfile(t, "admin")
+	laterAdmin := createTestProfile(t, "later-admin")
+
+	leave := func(group models.Conversations, profile models.Profile) {
+		t.Helper()
+		authenticateAs(t, profile)
+		if w := serve("DELETE", fmt.Sprintf("/?conversation_id=%d", group.ID), LeaveGroup); w.Code != 200 {
+			t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	t.Run("to the longest-standing admin", func(t *testing.T) {
+		group := createTestGroup(t,
+			[]models.Profile{owner, member, admin, laterAdmin},
+			[]models.MemberRole{models.RoleOwner, models.RoleMember, models.RoleAdmin, models.RoleAdmin})
+		leave(group, owner)
+
+		if _, present := memberRole(t, group, owner); present {
+			t.Error("owner is still a member")
+		}
+		if role, _ := memberRole(t, group, admin); role != models.RoleOwner {
+			t.Errorf("admin role = %q, want owner", role)
+		}
+		for _, profile := range []models.Profile{member, laterAdmin} {
+			if role, _ := memberRole(t, group, profile); role == models.RoleOwner {
+				t.Errorf("%s became an owner too", profile.Username)
+			}
+		}
+	})
+
+	t.Run("to the longest-standing member without admins", func(t *testing.T) {
+		group := createTestGroup(t,
+			[]models.Profile{owner, member, laterAdmin},
+			[]models.MemberRole{models.RoleOwner, models.RoleMember, models.RoleMember})
+		leave(group, owner)
+
+		if role, _ := memberRole(t, group, member); role != models.RoleOwner {
+			t.Errorf("member role = %q, want owner", role)
+		}
+	})
+
+	t.Run("member leaving keeps the owner", func(t *testing.T) {
+		group := createTestGroup(t,
+			[]models.Profile{owner, member},
+			[]models.MemberRole{models.RoleOwner, models.RoleMember})
+		leave(group, member)
+
+		if role, _ := memberRole(t, group, owner); role != models.RoleOwner {
+			t.Errorf("owner role = %q, want owner", role)
+		}
+	})
+}
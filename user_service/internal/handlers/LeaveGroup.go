@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"user_service/internal/database"
+	"user_service/internal/models"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type leaveGroupRequest struct {
+	ConversationID uint `form:"conversation_id" binding:"required"`
+}
+
+// LeaveGroup ends the caller's membership. An owner who leaves hands the
+// group over first, see transferOwnership.
+func LeaveGroup(c *gin.Context) {
+	var req leaveGroupRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	group, member, err := loadGroup(tx, req.ConversationID, profile.ID)
+	if err == nil && member.Role == models.RoleOwner {
+		err = transferOwnership(tx, group, member)
+	}
+	if err == nil {
+		err = removeGroupMember(tx, group, profile.ID, member, actionMemberLeft)
+	}
+	if err != nil {
+		tx.Rollback()
+		status := groupChangeStatus(err)
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Cannot leave group",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to commit transaction",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, utils.Response{
+		Code:    200,
+		Success: true,
+		Message: "Left group",
+		Error:   nil,
+	})
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"user_service/internal/database"
+	"user_service/internal/models"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const defaultConversationPageSize = 30
+
+type listConversationsRequest struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// conversationSummary is one sidebar entry. Participants excludes the
+// current profile.
+type conversationSummary struct {
+	ID               uint                    `json:"id"`
+	ConversationType models.ConversationType `json:"conversation_type"`
+	Name             *string                 `json:"name,omitempty"`
+	Avatar           *models.Attachment      `json:"avatar,omitempty"`
+	Participants     []models.Profile        `json:"participants"`
+	LastMessage      *models.Messages        `json:"last_message"`
+	UnreadCount      int64                   `json:"unread_count"`
+	LastActivityAt   time.Time               `json:"last_activity_at"`
+}
+
+type listConversationsResponse struct {
+	utils.Response
+	Data       []conversationSummary `json:"data"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// conversationActivity is a page row: when the conversation was last active,
+// which message made it so and how much of it the profile has not read.
+type conversationActivity struct {
+	ID            uint
+	LastMessageID *uint
+	LastActivity  time.Time
+	UnreadCount   int64
+}
+
+// conversationActivityQuery lists the profile's conversations by last
+// activity, newest first. Receipts are recorded up to a message, so the
+// newest message the profile read marks where unread messages start; only
+// messages sent since the profile joined count, and its own and deleted
+// messages never do.
+const conversationActivityQuery = `
+SELECT conversations.id,
+	last_message.id AS last_message_id,
+	COALESCE(last_message.created_at, conversations.created_at) AS last_activity,
+	(SELECT COUNT(*) FROM messages
+		WHERE messages.conversation_id = conversations.id
+		AND messages.id > COALESCE(last_read.message_id, 0)
+		AND messages.created_at >= membership.created_at
+		AND messages.deleted_at IS NULL
+		AND messages.retracted_at IS NULL
+		AND messages.sender_id <> membership.user_id) AS unread_count
+FROM conversations
+JOIN conversation_members AS membership ON membership.conversation_id = conversations.id
+	AND membership.user_id = ? AND membership.deleted_at IS NULL
+LEFT JOIN LATERAL (SELECT messages.id, messages.created_at FROM messages
+	WHERE messages.conversation_id = conversations.id AND messages.deleted_at IS NULL
+	ORDER BY messages.id DESC LIMIT 1) AS last_message ON true
+LEFT JOIN LATERAL (SELECT message_receipts.message_id FROM message_receipts
+	JOIN messages ON messages.id = message_receipts.message_id
+	WHERE messages.conversation_id = conversations.id
+	AND message_receipts.profile_id = membership.user_id
+	AND message_receipts.read_at IS NOT NULL
+	ORDER BY message_receipts.message_id DESC LIMIT 1) AS last_read ON true
+WHERE conversations.deleted_at IS NULL
+%s
+ORDER BY last_activity DESC, conversations.id DESC
+LIMIT ?`
+
+var errMalformedCursor = errors.New("malformed cursor")
+
+// The cursor is the last activity, in Unix microseconds to match Postgres
+// timestamp precision, and id of the last conversation of the previous page.
+func encodeConversationCursor(row conversationActivity) string {
+	return fmt.Sprintf("%d_%d", row.LastActivity.UnixMicro(), row.ID)
+}
+
+func decodeConversationCursor(cursor string) (time.Time, uint, error) {
+	micros, id, found := strings.Cut(cursor, "_")
+	if !found {
+		return time.Time{}, 0, errMalformedCursor
+	}
+	ts, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, errMalformedCursor
+	}
+	convID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, errMalformedCursor
+	}
+	return time.UnixMicro(ts), uint(convID), nil
+}
+
+func conversationActivityPage(tx *gorm.DB, profileID uint, cursor string, limit int) ([]conversationActivity, error) {
+	args := []interface{}{profileID}
+	cursorCondition := ""
+	if cursor != "" {
+		activity, convID, err := decodeConversationCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursorCondition = "AND (COALESCE(last_message.created_at, conversations.created_at), conversations.id) < (?, ?)"
+		args = append(args, activity, convID)
+	}
+	args = append(args, limit)
+
+	var rows []conversationActivity
+	err := tx.Raw(fmt.Sprintf(conversationActivityQuery, cursorCondition), args...).Scan(&rows).Error
+	return rows, err
+}
+
+// ListConversations returns every conversation of the current profile for
+// the sidebar, most recently active first.
+func ListConversations(c *gin.Context) {
+	var req listConversationsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultConversationPageSize
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	// One extra row tells whether another page follows.
+	rows, err := conversationActivityPage(tx, profile.ID, req.Cursor, req.Limit+1)
+	if err != nil {
+		tx.Rollback()
+		status := 500
+		if errors.Is(err, errMalformedCursor) {
+			status = 400
+		}
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Failed to list conversations",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	nextCursor := ""
+	if len(rows) > req.Limit {
+		rows = rows[:req.Limit]
+		nextCursor = encodeConversationCursor(rows[len(rows)-1])
+	}
+
+	conversationIDs := make([]uint, 0, len(rows))
+	var lastMessageIDs []uint
+	for _, row := range rows {
+		conversationIDs = append(conversationIDs, row.ID)
+		if row.LastMessageID != nil {
+			lastMessageIDs = append(lastMessageIDs, *row.LastMessageID)
+		}
+	}
+
+	var conversations []models.Conversations
+	if len(conversationIDs) > 0 {
+		if err := tx.Preload("Members.User").Preload("Avatar").
+			Where("id IN ?", conversationIDs).
+			Find(&conversations).Error; err != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Failed to load conversations",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+	conversationByID := make(map[uint]models.Conversations, len(conversations))
+	for _, conversation := range conversations {
+		conversationByID[conversation.ID] = conversation
+	}
+
+	var lastMessages []models.Messages
+	if len(lastMessageIDs) > 0 {
+		if err := tx.Preload("Content").Where("id IN ?", lastMessageIDs).Find(&lastMessages).Error; err != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Failed to load last messages",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+	lastMessageByID := make(map[uint]*models.Messages, len(lastMessages))
+	for i := range lastMessages {
+		lastMessageByID[lastMessages[i].ID] = &lastMessages[i]
+	}
+
+	summaries := make([]conversationSummary, 0, len(rows))
+	for _, row := range rows {
+		conversation := conversationByID[row.ID]
+		summary := conversationSummary{
+			ID:               row.ID,
+			ConversationType: conversation.ConversationType,
+			Name:             conversation.Name,
+			Avatar:           conversation.Avatar,
+			Participants:     otherParticipants(conversation, profile.ID),
+			UnreadCount:      row.UnreadCount,
+			LastActivityAt:   row.LastActivity,
+		}
+		if row.LastMessageID != nil {
+			summary.LastMessage = lastMessageByID[*row.LastMessageID]
+		}
+		summaries = append(summaries, summary)
+	}
+
+	tx.Commit()
+	c.JSON(200, listConversationsResponse{
+		Response: utils.Response{
+			Code:    200,
+			Success: true,
+			Message: "Conversations retrieved successfully",
+		},
+		Data:       summaries,
+		NextCursor: nextCursor,
+	})
+}
+
+// otherParticipants lists the members of the conversation except profileID.
+func otherParticipants(conversation models.Conversations, profileID uint) []models.Profile {
+	seen := map[uint]bool{profileID: true}
+	participants := []models.Profile{}
+	for _, member := range conversation.Members {
+		if member.User.ID == 0 || seen[member.User.ID] {
+			continue
+		}
+		seen[member.User.ID] = true
+		participants = append(participants, member.User)
+	}
+	return participants
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+	"user_service/internal/database"
+	"user_service/internal/models"
+)
+
+func TestConversationCursorRoundTrip(t *testing.T) {
+	row := conversationActivity{ID: 42, LastActivity: time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)}
+
+	activity, id, err := decodeConversationCursor(encodeConversationCursor(row))
+	if err != nil {
+		t.Fatalf("decodeConversationCursor: %v", err)
+	}
+	if id != row.ID {
+		t.Errorf("id = %d, want %d", id, row.ID)
+	}
+	if want := row.LastActivity.Truncate(time.Microsecond); !activity.Equal(want) {
+		t.Errorf("activity = %v, want %v", activity, want)
+	}
+
+	for _, cursor := range []string{"", "123", "abc_1", "123_abc", "123_-1"} {
+		if _, _, err := decodeConversationCursor(cursor); err != errMalformedCursor {
+			t.Errorf("decodeConversationCursor(%q) = %v, want errMalformedCursor", cursor, err)
+		}
+	}
+}
+
+func TestListConversationsUnreadCount(t *testing.T) {
+	setupTestDB(t)
+
+	alice := createTestProfile(t, "alice")
+	bob := createTestProfile(t, "bob")
+	carol := createTestProfile(t, "carol")
+
+	start := time.Now().Add(-time.Hour)
+	name := "team"
+	group := models.Conversations{ConversationType: models.Group, Name: &name}
+	if err := database.DB.Create(&group).Error; err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.Exec("DELETE FROM message_receipts WHERE message_id IN (SELECT id FROM messages WHERE conversation_id = ?)", group.ID)
+		database.DB.Unscoped().Where("conversation_id = ?", group.ID).Delete(&models.Messages{})
+		database.DB.Unscoped().Where("conversation_id = ?", group.ID).Delete(&models.ConversationMember{})
+		database.DB.Unscoped().Delete(&group)
+	})
+
+	join := func(profile models.Profile, at time.Time) {
+		t.Helper()
+		member := models.ConversationMember{ConversationID: group.ID, UserID: profile.ID}
+		member.CreatedAt = at
+		if err := database.DB.Create(&member).Error; err != nil {
+			t.Fatalf("failed to add member: %v", err)
+		}
+	}
+	send := func(at time.Time) models.Messages {
+		t.Helper()
+		message := models.Messages{ConversationID: group.ID, SenderID: alice.ID}
+		message.CreatedAt = at
+		if err := database.DB.Create(&message).Error; err != nil {
+			t.Fatalf("failed to create message: %v", err)
+		}
+		return message
+	}
+
+	join(alice, start)
+	join(bob, start)
+	send(start.Add(time.Minute))
+	join(carol, start.Add(2*time.Minute))
+	read := send(start.Add(3 * time.Minute))
+	send(start.Add(4 * time.Minute))
+
+	now := time.Now()
+	if err := database.DB.Create(&models.MessageReceipt{
+		MessageID:   read.ID,
+		ProfileID:   carol.ID,
+		DeliveredAt: &now,
+		ReadAt:      &now,
+	}).Error; err != nil {
+		t.Fatalf("failed to create receipt: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		profile models.Profile
+		unread  int64
+	}{
+		{"member from the start", bob, 3},
+		{"joined later and read up to a message", carol, 1},
+		{"sender", alice, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticateAs(t, tt.profile)
+			w := serve("GET", "/", ListConversations)
+			if w.Code != 200 {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			var resp listConversationsResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(resp.Data) != 1 || resp.Data[0].ID != group.ID {
+				t.Fatalf("conversations = %+v, want only %d", resp.Data, group.ID)
+			}
+			if resp.Data[0].UnreadCount != tt.unread {
+				t.Errorf("unread = %d, want %d", resp.Data[0].UnreadCount, tt.unread)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"time"
+	"user_service/internal/database"
+	"user_service/internal/events"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type markConversationReadRequest struct {
+	ConversationID uint `json:"conversation_id" binding:"required"`
+	MessageID      uint `json:"message_id" binding:"required"`
+}
+
+func MarkConversationRead(c *gin.Context) {
+	var req markConversationReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	participant, err := isParticipant(tx, req.ConversationID, profile.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to check conversation access",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if !participant {
+		tx.Rollback()
+		c.JSON(403, utils.Response{
+			Code:    403,
+			Success: false,
+			Message: "Forbidden",
+			Error:   "Not a participant of this conversation",
+		})
+		return
+	}
+
+	// record_receipts is created by the migrations and shared with the
+	// receipt frames of chat_service.
+	now := time.Now()
+	if err := tx.Exec("SELECT record_receipts(?, ?, ?, ?, ?)",
+		req.ConversationID, profile.ID, req.MessageID, true, now).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to mark messages as read",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := events.PublishToConversation(tx, req.ConversationID, events.Envelope{
+		Type:           "receipt",
+		ConversationID: req.ConversationID,
+		MessageID:      req.MessageID,
+		SenderID:       profile.ID,
+		Status:         "read",
+		ServerTime:     &now,
+	}); err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to publish read receipt",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to commit transaction",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, utils.Response{
+		Code:    200,
+		Success: true,
+		Message: "Conversation marked as read",
+		Error:   nil,
+	})
+}
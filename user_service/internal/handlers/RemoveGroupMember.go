@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"user_service/internal/database"
+	"user_service/internal/models"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type removeGroupMemberRequest struct {
+	ConversationID uint `form:"conversation_id" binding:"required"`
+	MemberID       uint `form:"member_id" binding:"required"`
+}
+
+// RemoveGroupMember takes another member out of the group. Admins can remove
+// members, only the owner can remove admins, and nobody can remove the owner.
+// Members remove themselves with LeaveGroup.
+func RemoveGroupMember(c *gin.Context) {
+	var req removeGroupMemberRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+	if req.MemberID == profile.ID {
+		tx.Rollback()
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   "use leave_group to leave a group",
+		})
+		return
+	}
+
+	group, actor, err := loadGroupAsAdmin(tx, req.ConversationID, profile.ID)
+	var member models.ConversationMember
+	if err == nil {
+		member, err = loadGroupMember(tx, group.ID, req.MemberID)
+	}
+	if err == nil {
+		switch {
+		case member.Role == models.RoleOwner:
+			err = errOwnerRole
+		case member.Role == models.RoleAdmin && actor.Role != models.RoleOwner:
+			err = errNotGroupOwner
+		}
+	}
+	if err == nil {
+		err = removeGroupMember(tx, group, profile.ID, member, actionMemberRemoved)
+	}
+	if err != nil {
+		tx.Rollback()
+		status := groupChangeStatus(err)
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Cannot remove group member",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to commit transaction",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, utils.Response{
+		Code:    200,
+		Success: true,
+		Message: "Group member removed",
+		Error:   nil,
+	})
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"user_service/internal/database"
+	"user_service/internal/events"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type renameGroupRequest struct {
+	ConversationID uint   `json:"conversation_id" binding:"required"`
+	Name           string `json:"name" binding:"required"`
+}
+
+// RenameGroup changes the name of a group. Only admins can rename.
+func RenameGroup(c *gin.Context) {
+	var req renameGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+	name, ok := cleanGroupName(req.Name)
+	if !ok {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   "name must be 1 to 100 characters",
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	group, _, err := loadGroupAsAdmin(tx, req.ConversationID, profile.ID)
+	if err != nil {
+		tx.Rollback()
+		status := groupChangeStatus(err)
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Cannot rename group",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Model(&group).Update("name", name).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to rename group",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	err = events.PublishToConversation(tx, group.ID, events.Envelope{
+		Type:           "conversation_renamed",
+		ConversationID: group.ID,
+		SenderID:       profile.ID,
+		Name:           name,
+	})
+	if err == nil {
+		err = postSystemMessage(tx, group.ID, systemEvent{
+			Action:  actionGroupRenamed,
+			ActorID: profile.ID,
+			Name:    name,
+		})
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to publish rename",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to commit transaction",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, utils.Response{
+		Code:    200,
+		Success: true,
+		Message: "Group renamed",
+		Error:   nil,
+	})
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"user_service/internal/database"
+	"user_service/internal/models"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type setGroupAvatarRequest struct {
+	ConversationID uint `json:"conversation_id" binding:"required"`
+	// AttachmentID is an image uploaded to the group; 0 clears the avatar.
+	AttachmentID uint `json:"attachment_id"`
+}
+
+// SetGroupAvatar changes the picture of a group. Only admins can change it.
+func SetGroupAvatar(c *gin.Context) {
+	var req setGroupAvatarRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	group, _, err := loadGroupAsAdmin(tx, req.ConversationID, profile.ID)
+	var avatarID *uint
+	if err == nil && req.AttachmentID != 0 {
+		var attachment models.Attachment
+		err = tx.Where("id = ? AND conversation_id = ? AND content_type = ?",
+			req.AttachmentID, group.ID, models.ContentTypeImage).First(&attachment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errInvalidAvatar
+		}
+		avatarID = &attachment.ID
+	}
+	if err == nil {
+		err = tx.Model(&group).Update("avatar_id", avatarID).Error
+	}
+	if err == nil {
+		err = postSystemMessage(tx, group.ID, systemEvent{
+			Action:   actionAvatarChanged,
+			ActorID:  profile.ID,
+			AvatarID: req.AttachmentID,
+		})
+	}
+	if err != nil {
+		tx.Rollback()
+		status := groupChangeStatus(err)
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Cannot change group avatar",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to commit transaction",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, utils.Response{
+		Code:    200,
+		Success: true,
+		Message: "Group avatar updated",
+		Error:   nil,
+	})
+}
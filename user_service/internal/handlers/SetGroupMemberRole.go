@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"user_service/internal/database"
+	"user_service/internal/models"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type setGroupMemberRoleRequest struct {
+	ConversationID uint              `json:"conversation_id" binding:"required"`
+	MemberID       uint              `json:"member_id" binding:"required"`
+	Role           models.MemberRole `json:"role" binding:"required,oneof=admin member"`
+}
+
+// SetGroupMemberRole promotes a member to admin or demotes an admin. Admins
+// can promote; only the owner can demote. The owner's own role changes only
+// when they leave.
+func SetGroupMemberRole(c *gin.Context) {
+	var req setGroupMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, tx)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	group, actor, err := loadGroupAsAdmin(tx, req.ConversationID, profile.ID)
+	var member models.ConversationMember
+	if err == nil {
+		member, err = loadGroupMember(tx, group.ID, req.MemberID)
+	}
+	if err == nil {
+		switch {
+		case member.Role == models.RoleOwner:
+			err = errOwnerRole
+		case member.Role == models.RoleAdmin && req.Role == models.RoleMember && actor.Role != models.RoleOwner:
+			err = errNotGroupOwner
+		}
+	}
+	if err == nil && member.Role != req.Role {
+		err = setMemberRole(tx, group, profile.ID, member, req.Role)
+	}
+	if err != nil {
+		tx.Rollback()
+		status := groupChangeStatus(err)
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Cannot change member role",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to commit transaction",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, utils.Response{
+		Code:    200,
+		Success: true,
+		Message: "Member role updated",
+		Error:   nil,
+	})
+}
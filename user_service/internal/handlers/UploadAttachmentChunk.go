@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+	"user_service/internal/database"
+	"user_service/internal/models"
+	"user_service/internal/storage"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type uploadAttachmentChunkRequest struct {
+	UploadID uint  `form:"upload_id" binding:"required"`
+	Offset   int64 `form:"offset" binding:"min=0"`
+}
+
+// sniffLength is how much of a file http.DetectContentType looks at.
+const sniffLength = 512
+
+// offsetMismatchResponse tells the client how many bytes of the upload were
+// received, so it can resume from there.
+func offsetMismatchResponse(upload models.AttachmentUpload) attachmentUploadResponse {
+	return attachmentUploadResponse{
+		Response: utils.Response{
+			Code:    409,
+			Success: false,
+			Message: "Chunk does not start at the received offset",
+			Error:   "offset mismatch",
+		},
+		Data: attachmentUploadData{
+			UploadID:  upload.ID,
+			Received:  upload.Received,
+			Size:      upload.Size,
+			ChunkSize: maxAttachmentChunk,
+			ExpiresAt: upload.ExpiresAt,
+		},
+	}
+}
+
+// UploadAttachmentChunk appends the raw request body to an upload. offset
+// must equal the bytes received so far; a 409 response carries that value so
+// an interrupted upload can resume.
+func UploadAttachmentChunk(c *gin.Context) {
+	var req uploadAttachmentChunkRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			c.JSON(500, utils.Response{
+				Code:    500,
+				Success: false,
+				Message: "Internal Server error",
+				Error:   "Internal Server error, try again",
+			})
+		}
+	}()
+
+	profile, ok := currentProfile(c, database.DB)
+	if !ok {
+		return
+	}
+
+	// No transaction is held while the chunk streams in, so a slow client
+	// never pins a connection or blocks CompleteAttachmentUpload. The offset
+	// is checked here and again, atomically, when the chunk is recorded.
+	var upload models.AttachmentUpload
+	if err := database.DB.Where("id = ? AND profile_id = ?", req.UploadID, profile.ID).
+		First(&upload).Error; err != nil {
+		status := 500
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = 404
+		}
+		c.JSON(status, utils.Response{
+			Code:    status,
+			Success: false,
+			Message: "Upload not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if upload.ExpiresAt.Before(time.Now()) {
+		c.JSON(410, utils.Response{
+			Code:    410,
+			Success: false,
+			Message: "Upload expired",
+			Error:   "start a new upload",
+		})
+		return
+	}
+	if req.Offset != upload.Received || upload.Received == upload.Size {
+		c.JSON(409, offsetMismatchResponse(upload))
+		return
+	}
+
+	remaining := upload.Size - upload.Received
+	body := io.Reader(http.MaxBytesReader(c.Writer, c.Request.Body, maxAttachmentChunk))
+
+	if upload.Received == 0 {
+		head := make([]byte, sniffLength)
+		n, err := io.ReadFull(body, head)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			c.JSON(400, utils.Response{
+				Code:    400,
+				Success: false,
+				Message: "Failed to read chunk",
+				Error:   err.Error(),
+			})
+			return
+		}
+		head = head[:n]
+
+		mimeType, err := sniffMimeType(head, upload.ContentType)
+		if err != nil {
+			c.JSON(415, utils.Response{
+				Code:    415,
+				Success: false,
+				Message: "Unsupported file type",
+				Error:   err.Error(),
+			})
+			return
+		}
+		upload.MimeType = mimeType
+		body = io.MultiReader(bytes.NewReader(head), body)
+	}
+
+	written, err := storage.Blobs.Append(uploadKey(upload), upload.Received, io.LimitReader(body, remaining+1))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || written > remaining {
+		c.JSON(413, utils.Response{
+			Code:    413,
+			Success: false,
+			Message: "Chunk too large",
+			Error:   "chunk exceeds the chunk size or the declared file size",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to store chunk",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if written == 0 {
+		c.JSON(400, utils.Response{
+			Code:    400,
+			Success: false,
+			Message: "Invalid data",
+			Error:   "chunk is empty",
+		})
+		return
+	}
+
+	// Compare-and-set: a chunk that raced another one for the same offset
+	// loses and is told where the upload stands.
+	result := database.DB.Model(&models.AttachmentUpload{}).
+		Where("id = ? AND received = ?", upload.ID, upload.Received).
+		Updates(map[string]interface{}{
+			"received":  upload.Received + written,
+			"mime_type": upload.MimeType,
+		})
+	if result.Error != nil {
+		c.JSON(500, utils.Response{
+			Code:    500,
+			Success: false,
+			Message: "Failed to record chunk",
+			Error:   result.Error.Error(),
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		if err := database.DB.Where("id = ?", upload.ID).First(&upload).Error; err != nil {
+			status := 500
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				status = 404
+			}
+			c.JSON(status, utils.Response{
+				Code:    status,
+				Success: false,
+				Message: "Upload not found",
+				Error:   err.Error(),
+			})
+			return
+		}
+		c.JSON(409, offsetMismatchResponse(upload))
+		return
+	}
+	upload.Received += written
+
+	c.JSON(200, attachmentUploadResponse{
+		Response: utils.Response{
+			Code:    200,
+			Success: true,
+			Message: "Chunk stored",
+		},
+		Data: attachmentUploadData{
+			UploadID:  upload.ID,
+			Received:  upload.Received,
+			Size:      upload.Size,
+			ChunkSize: maxAttachmentChunk,
+			ExpiresAt: upload.ExpiresAt,
+		},
+	})
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+	"user_service/internal/database"
+	"user_service/internal/models"
+	"user_service/internal/storage"
+)
+
+func TestUploadAttachmentChunk(t *testing.T) {
+	setupTestDB(t)
+
+	blobs, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	previous := storage.Blobs
+	storage.Blobs = blobs
+	t.Cleanup(func() { storage.Blobs = previous })
+
+	alice := createTestProfile(t, "alice")
+	authenticateAs(t, alice)
+
+	content := append([]byte("%PDF-1.7\n"), bytes.Repeat([]byte("x"), 991)...)
+	upload := models.AttachmentUpload{
+		ProfileID:   alice.ID,
+		ContentType: models.ContentTypeFile,
+		MimeType:    "application/pdf",
+		FileName:    "notes.pdf",
+		Size:        int64(len(content)),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	if err := database.DB.Create(&upload).Error; err != nil {
+		t.Fatalf("failed to create upload: %v", err)
+	}
+	t.Cleanup(func() { database.DB.Unscoped().Delete(&upload) })
+
+	send := func(offset int, chunk []byte) (int, attachmentUploadResponse) {
+		t.Helper()
+		target := fmt.Sprintf("/?upload_id=%d&offset=%d", upload.ID, offset)
+		w := serveBody("PUT", target, bytes.NewReader(chunk), UploadAttachmentChunk)
+		var resp attachmentUploadResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v: %s", err, w.Body.String())
+		}
+		return w.Code, resp
+	}
+
+	if status, resp := send(0, content[:600]); status != 200 || resp.Data.Received != 600 {
+		t.Fatalf("first chunk: status %d, received %d", status, resp.Data.Received)
+	}
+
+	// A retry of the first chunk after it was recorded learns where to resume.
+	status, resp := send(0, content[:600])
+	if status != 409 || resp.Data.Received != 600 {
+		t.Fatalf("stale chunk: status %d, received %d, want 409 and 600", status, resp.Data.Received)
+	}
+
+	if status, resp := send(int(resp.Data.Received), content[600:]); status != 200 || resp.Data.Received != upload.Size {
+		t.Fatalf("last chunk: status %d, received %d", status, resp.Data.Received)
+	}
+	if status, resp := send(int(upload.Size), []byte("x")); status != 409 || resp.Data.Received != upload.Size {
+		t.Fatalf("chunk past the end: status %d, received %d", status, resp.Data.Received)
+	}
+
+	var stored models.AttachmentUpload
+	if err := database.DB.First(&stored, upload.ID).Error; err != nil {
+		t.Fatalf("failed to reload upload: %v", err)
+	}
+	if stored.Received != upload.Size || stored.MimeType != "application/pdf" {
+		t.Errorf("upload = received %d, mime %q", stored.Received, stored.MimeType)
+	}
+
+	blob, err := blobs.Commit(uploadKey(upload))
+	if err != nil {
+		t.Fatalf("failed to commit upload: %v", err)
+	}
+	r, err := blobs.Open(blob.Key)
+	if err != nil {
+		t.Fatalf("failed to open blob: %v", err)
+	}
+	defer r.Close()
+	var got bytes.Buffer
+	if _, err := got.ReadFrom(r); err != nil {
+		t.Fatalf("failed to read blob: %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), content) {
+		t.Errorf("stored %d bytes, want the %d uploaded", got.Len(), len(content))
+	}
+}
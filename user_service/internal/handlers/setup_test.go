@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+	"user_service/internal/database"
+	"user_service/internal/models"
+	"user_service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// setupTestDB points database.DB at TEST_DATABASE_URL and migrates the tables
+// the handlers use. Tests that need a database skip without one.
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to the test database: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&models.Profile{},
+		&models.Attachment{},
+		&models.AttachmentUpload{},
+		&models.Conversations{},
+		&models.LinkPreview{},
+		&models.Messages{},
+		&models.MessageContent{},
+		&models.MessageReceipt{},
+		&models.ConversationMember{},
+		&models.BrokerPayload{},
+	); err != nil {
+		t.Fatalf("failed to migrate the test database: %v", err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+}
+
+// authenticateAs answers VERIFY_TOKEN_CLAIMS for profile, whatever token the
+// request carries.
+func authenticateAs(t *testing.T, profile models.Profile) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(utils.VerifyResp{
+			Response: utils.Response{Code: 200, Success: true},
+			User:     utils.JwtClaims{Email: profile.Email, UserID: profile.ID},
+		})
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("VERIFY_TOKEN_CLAIMS", server.URL)
+}
+
+// createTestProfile stores a profile with unique fields and deletes it when
+// the test ends.
+func createTestProfile(t *testing.T, name string) models.Profile {
+	t.Helper()
+	unique := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	profile := models.Profile{
+		Email:     unique + "@example.com",
+		Username:  unique,
+		PublicKey: unique,
+	}
+	if err := database.DB.Create(&profile).Error; err != nil {
+		t.Fatalf("failed to create profile: %v", err)
+	}
+	t.Cleanup(func() { database.DB.Unscoped().Delete(&profile) })
+	return profile
+}
+
+// serve runs a single request through handler.
+func serve(method, target string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	return serveBody(method, target, nil, handler)
+}
+
+// serveBody is serve with a request body.
+func serveBody(method, target string, body io.Reader, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, "/", handler)
+
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("Authorization", "Bearer test")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
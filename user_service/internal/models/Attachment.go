@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Attachment is an uploaded file that messages of ConversationID can
+// reference. Its bytes live in storage under StorageKey, which several
+// attachments share when the same file is uploaded again.
+type Attachment struct {
+	gorm.Model
+	ConversationID uint        `gorm:"not null;index"`
+	UploaderID     uint        `gorm:"not null;index"`
+	ContentType    ContentType `gorm:"not null"`
+	MimeType       string      `gorm:"not null"`
+	FileName       string      `gorm:"not null"`
+	Size           int64       `gorm:"not null"`
+	StorageKey     string      `gorm:"not null;index"`
+}
+
+// AttachmentUpload tracks a chunked upload until it is completed into an
+// Attachment or expires. Received is the offset the next chunk must start at.
+type AttachmentUpload struct {
+	gorm.Model
+	ConversationID uint        `gorm:"not null;index"`
+	ProfileID      uint        `gorm:"not null;index"`
+	ContentType    ContentType `gorm:"not null"`
+	MimeType       string      `gorm:"not null"`
+	FileName       string      `gorm:"not null"`
+	Size           int64       `gorm:"not null"`
+	Received       int64       `gorm:"not null;default:0"`
+	ExpiresAt      time.Time   `gorm:"not null;index"`
+}
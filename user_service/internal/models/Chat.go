@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -13,6 +15,10 @@ const (
 	ContentTypeVideo ContentType = "video"
 	ContentTypeAudio ContentType = "audio"
 	ContentTypeLink  ContentType = "link"
+
+	// ContentTypeSystem parts are written by the server to record group
+	// changes. Their content is a JSON object with an "action" field.
+	ContentTypeSystem ContentType = "system"
 )
 
 type RequestStatus string
@@ -30,42 +36,116 @@ const (
 	Group    ConversationType = "group"
 )
 
+type MemberRole string
+
+const (
+	RoleOwner  MemberRole = "owner"
+	RoleAdmin  MemberRole = "admin"
+	RoleMember MemberRole = "member"
+)
+
 type Conversations struct {
 	gorm.Model
 
 	ConversationType ConversationType `gorm:"not null;index"`
 
+	// Name and Avatar are only set on groups. The avatar is an image
+	// attachment uploaded to the group.
+	Name     *string     `gorm:"default:null"`
+	AvatarID *uint       `gorm:"default:null"`
+	Avatar   *Attachment `gorm:"foreignKey:AvatarID"`
+
 	PrivateKey *string `gorm:"uniqueIndex:idx_keys_nullable"`
 	PublicKey  *string `gorm:"uniqueIndex:idx_keys_nullable"`
 
-	Profile1ID uint    `gorm:"not null;index:idx_unique_conversation,unique"`
-	Profile1   Profile `gorm:"foreignKey:Profile1ID"`
+	// Profile1ID and Profile2ID identify the two sides of a one-to-one
+	// conversation and keep it unique; groups leave them null. Access is
+	// decided by Members for both kinds.
+	Profile1ID *uint    `gorm:"index:idx_unique_conversation,unique"`
+	Profile1   *Profile `gorm:"foreignKey:Profile1ID"`
 
-	Profile2ID uint    `gorm:"not null;index:idx_unique_conversation,unique"`
-	Profile2   Profile `gorm:"foreignKey:Profile2ID"`
+	Profile2ID *uint    `gorm:"index:idx_unique_conversation,unique"`
+	Profile2   *Profile `gorm:"foreignKey:Profile2ID"`
 
 	Members []ConversationMember `gorm:"foreignKey:ConversationID"`
 
 	Messages []Messages `gorm:"foreignKey:ConversationID"`
 }
 
+// ConversationMember grants a profile access to a conversation. Leaving or
+// being removed soft-deletes the row, so a profile can rejoin later.
 type ConversationMember struct {
 	gorm.Model
-	ConversationID uint    `gorm:"not null;index"`
-	UserID         uint    `gorm:"not null;index"`
+	ConversationID uint    `gorm:"not null;index;uniqueIndex:idx_active_member,where:deleted_at IS NULL"`
+	UserID         uint    `gorm:"not null;index;uniqueIndex:idx_active_member,where:deleted_at IS NULL"`
 	User           Profile `gorm:"foreignKey:UserID"`
+
+	// Role only matters in groups, which have exactly one owner.
+	Role MemberRole `gorm:"not null;default:member"`
 }
 
 type Messages struct {
 	gorm.Model
-	ConversationID uint `gorm:"not null"`
+	ConversationID uint `gorm:"not null;index:idx_conversation_sender_client_msg,unique,priority:1"`
 
-	SenderID uint    `gorm:"not null"`
+	SenderID uint    `gorm:"not null;index:idx_conversation_sender_client_msg,unique,priority:2"`
 	Sender   Profile `gorm:"foreignKey:SenderID"`
 
+	// ClientMsgID is the sender's idempotency key within a conversation;
+	// retries of the same message resolve to the row that was already
+	// stored.
+	ClientMsgID *string `gorm:"index:idx_conversation_sender_client_msg,unique,priority:3"`
+
+	// ParentID is the root of the thread this message replies to. Threads are
+	// flat: a reply to a reply is attached to the same root.
+	ParentID *uint `gorm:"index"`
+
+	// IsRead is only set in one-to-one conversations; groups track reads
+	// per member in Receipts.
 	IsRead bool `gorm:"default:false"`
 
+	// IsSystem marks messages the server wrote; nobody can edit or delete
+	// them.
+	IsSystem bool `gorm:"not null;default:false"`
+
+	EditedAt *time.Time `gorm:"default:null"`
+
+	// RetractedAt marks a tombstone: the sender deleted the message, its
+	// content and edit history are gone, but the row keeps its place.
+	RetractedAt *time.Time `gorm:"default:null"`
+
 	Content []MessageContent `gorm:"foreignKey:MessageID"`
+
+	Receipts []MessageReceipt `gorm:"foreignKey:MessageID"`
+
+	Reactions []MessageReaction `gorm:"foreignKey:MessageID"`
+}
+
+// MessageReceipt records when one participant received and read a message.
+type MessageReceipt struct {
+	gorm.Model
+	MessageID   uint       `gorm:"not null;uniqueIndex:idx_receipt_message_profile"`
+	ProfileID   uint       `gorm:"not null;uniqueIndex:idx_receipt_message_profile;index"`
+	DeliveredAt *time.Time `gorm:"default:null"`
+	ReadAt      *time.Time `gorm:"default:null"`
+}
+
+// MessageEdit keeps the content a message had before one edit, as the JSON
+// encoding of its content parts.
+type MessageEdit struct {
+	gorm.Model
+	MessageID       uint   `gorm:"not null;index"`
+	EditorID        uint   `gorm:"not null"`
+	PreviousContent string `gorm:"type:jsonb;not null"`
+}
+
+// MessageReaction is one emoji one participant put on a message. Removing a
+// reaction deletes the row, so the same emoji can be added again.
+type MessageReaction struct {
+	gorm.Model
+	MessageID uint   `gorm:"not null;uniqueIndex:idx_reaction_message_profile_emoji"`
+	ProfileID uint   `gorm:"not null;uniqueIndex:idx_reaction_message_profile_emoji"`
+	Emoji     string `gorm:"not null;uniqueIndex:idx_reaction_message_profile_emoji"`
 }
 
 type MessageContent struct {
@@ -73,6 +153,15 @@ type MessageContent struct {
 	MessageID   uint        `gorm:"not null"`
 	ContentType ContentType `gorm:"not null"`
 	Content     string      `gorm:"not null"`
+
+	// AttachmentID is set for image, file, video and audio parts; Content
+	// then holds an optional caption.
+	AttachmentID *uint       `gorm:"index"`
+	Attachment   *Attachment `gorm:"foreignKey:AttachmentID"`
+
+	// LinkPreviewID is filled in on link parts once their page was fetched.
+	LinkPreviewID *uint        `gorm:"index"`
+	LinkPreview   *LinkPreview `gorm:"foreignKey:LinkPreviewID"`
 }
 
 type FriendRequest struct {
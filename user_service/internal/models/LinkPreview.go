@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LinkPreview caches what a URL's page said about itself. URLHash is the hex
+// SHA-256 of URL. A failed fetch is cached too, with Failed set, so the same
+// broken link is not fetched for every message that contains it.
+type LinkPreview struct {
+	gorm.Model
+	URLHash     string    `gorm:"not null;uniqueIndex"`
+	URL         string    `gorm:"type:text;not null"`
+	Title       string    `gorm:"not null;default:''"`
+	Description string    `gorm:"not null;default:''"`
+	ImageURL    string    `gorm:"type:text;not null;default:''"`
+	SiteName    string    `gorm:"not null;default:''"`
+	Failed      bool      `gorm:"not null;default:false"`
+	FetchedAt   time.Time `gorm:"not null"`
+}
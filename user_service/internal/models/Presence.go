@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// PresenceConnection records that a chat_service replica holds at least one
+// connection of a profile. Replicas refresh SeenAt while the connections stay
+// open, so rows of a replica that stopped without cleaning up go stale.
+type PresenceConnection struct {
+	ReplicaID string    `gorm:"primaryKey"`
+	ProfileID uint      `gorm:"primaryKey;index"`
+	SeenAt    time.Time `gorm:"not null;index"`
+}
@@ -1,7 +1,10 @@
 package models
 
 func (c *Conversations) NormalizeProfiles() {
-	if c.Profile1ID > c.Profile2ID {
+	if c.Profile1ID == nil || c.Profile2ID == nil {
+		return
+	}
+	if *c.Profile1ID > *c.Profile2ID {
 		c.Profile1ID, c.Profile2ID = c.Profile2ID, c.Profile1ID
 		c.Profile1, c.Profile2 = c.Profile2, c.Profile1
 	}
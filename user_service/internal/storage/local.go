@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var (
+	uploadIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	blobKeyPattern  = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// Local stores blobs on disk as blobs/<first two hex digits>/<key> and stages
+// uploads as uploads/<id>, both under one root so a committed blob is moved
+// into place with a rename.
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) (*Local, error) {
+	for _, dir := range []string{"blobs", "uploads"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o750); err != nil {
+			return nil, err
+		}
+	}
+	return &Local{root: root}, nil
+}
+
+func (l *Local) uploadPath(uploadID string) (string, error) {
+	if !uploadIDPattern.MatchString(uploadID) {
+		return "", fmt.Errorf("invalid upload id %q", uploadID)
+	}
+	return filepath.Join(l.root, "uploads", uploadID), nil
+}
+
+func (l *Local) blobPath(key string) (string, error) {
+	if !blobKeyPattern.MatchString(key) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(l.root, "blobs", key[:2], key), nil
+}
+
+func (l *Local) Append(uploadID string, offset int64, r io.Reader) (int64, error) {
+	path, err := l.uploadPath(uploadID)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o640)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if err := f.Truncate(offset); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(f, r)
+	if err != nil {
+		return n, err
+	}
+	return n, f.Sync()
+}
+
+// Commit copies the staged upload into a temporary blob while hashing it,
+// rather than renaming it, so a chunk still being appended cannot change a
+// blob after its key was computed.
+func (l *Local) Commit(uploadID string) (Blob, error) {
+	path, err := l.uploadPath(uploadID)
+	if err != nil {
+		return Blob{}, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Blob{}, ErrNotFound
+		}
+		return Blob{}, err
+	}
+	defer f.Close()
+
+	tmp, err := os.CreateTemp(filepath.Join(l.root, "blobs"), "commit-*")
+	if err != nil {
+		return Blob{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), f)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Blob{}, err
+	}
+
+	blob := Blob{Key: hex.EncodeToString(hash.Sum(nil)), Size: size}
+	dest, err := l.blobPath(blob.Key)
+	if err != nil {
+		return Blob{}, err
+	}
+
+	// When the same bytes were committed before, the existing blob is kept.
+	if _, err := os.Stat(dest); err != nil {
+		if err := os.MkdirAll(filepath.Dir(dest), 0o750); err != nil {
+			return Blob{}, err
+		}
+		if err := os.Rename(tmp.Name(), dest); err != nil {
+			return Blob{}, err
+		}
+	}
+	return blob, os.Remove(path)
+}
+
+func (l *Local) Discard(uploadID string) error {
+	path, err := l.uploadPath(uploadID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Open(key string) (io.ReadSeekCloser, error) {
+	path, err := l.blobPath(key)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestLocal(t *testing.T) *Local {
+	t.Helper()
+	local, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	return local
+}
+
+func readBlob(t *testing.T, local *Local, key string) []byte {
+	t.Helper()
+	r, err := local.Open(key)
+	if err != nil {
+		t.Fatalf("Open(%q): %v", key, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading %q: %v", key, err)
+	}
+	return data
+}
+
+func TestLocalCommitIsContentAddressed(t *testing.T) {
+	local := newTestLocal(t)
+	content := []byte("hello, attachments")
+	sum := sha256.Sum256(content)
+
+	var keys []string
+	for _, id := range []string{"1", "2"} {
+		if _, err := local.Append(id, 0, bytes.NewReader(content)); err != nil {
+			t.Fatalf("Append(%s): %v", id, err)
+		}
+		blob, err := local.Commit(id)
+		if err != nil {
+			t.Fatalf("Commit(%s): %v", id, err)
+		}
+		if blob.Key != hex.EncodeToString(sum[:]) || blob.Size != int64(len(content)) {
+			t.Errorf("Commit(%s) = %+v, want the sha256 and size of the content", id, blob)
+		}
+		if _, err := os.Stat(filepath.Join(local.root, "uploads", id)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("staged upload %s still exists: %v", id, err)
+		}
+		keys = append(keys, blob.Key)
+	}
+	if keys[0] != keys[1] {
+		t.Errorf("same bytes got keys %q and %q", keys[0], keys[1])
+	}
+	if got := readBlob(t, local, keys[0]); !bytes.Equal(got, content) {
+		t.Errorf("blob = %q, want %q", got, content)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(local.root, "blobs"))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != keys[0][:2] {
+		t.Errorf("blobs dir holds %d entries, want only the %q shard", len(entries), keys[0][:2])
+	}
+}
+
+func TestLocalAppendResumesAtOffset(t *testing.T) {
+	local := newTestLocal(t)
+
+	if _, err := local.Append("1", 0, strings.NewReader("hello wrong")); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	// Retrying from offset 6 drops what was staged past it.
+	n, err := local.Append("1", 6, strings.NewReader("world"))
+	if err != nil || n != 5 {
+		t.Fatalf("Append = %d, %v", n, err)
+	}
+	blob, err := local.Commit("1")
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if got := readBlob(t, local, blob.Key); string(got) != "hello world" {
+		t.Errorf("blob = %q, want %q", got, "hello world")
+	}
+}
+
+func TestLocalRejectsBadKeys(t *testing.T) {
+	local := newTestLocal(t)
+
+	if _, err := local.Append("../escape", 0, strings.NewReader("x")); err == nil {
+		t.Error("Append accepted a path as upload id")
+	}
+	if _, err := local.Commit("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Commit(missing) = %v, want ErrNotFound", err)
+	}
+	if _, err := local.Open("../uploads/1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open(path) = %v, want ErrNotFound", err)
+	}
+	if _, err := local.Open(strings.Repeat("0", 64)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open(unknown) = %v, want ErrNotFound", err)
+	}
+	if err := local.Discard("missing"); err != nil {
+		t.Errorf("Discard(missing) = %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Blob is a committed upload. Key is the hex SHA-256 of its bytes, so the
+// same file uploaded twice is stored once.
+type Blob struct {
+	Key  string
+	Size int64
+}
+
+// Store keeps attachment bytes. Uploads are staged under an id chosen by the
+// caller, chunk by chunk, and become an immutable blob on Commit.
+type Store interface {
+	// Append writes r to the staged upload starting at offset, dropping
+	// anything staged past offset first so a failed chunk can be retried.
+	// It returns the number of bytes written.
+	Append(uploadID string, offset int64, r io.Reader) (int64, error)
+
+	// Commit turns the staged upload into a blob and removes the staging
+	// copy.
+	Commit(uploadID string) (Blob, error)
+
+	// Discard removes a staged upload. Discarding an unknown upload is not
+	// an error.
+	Discard(uploadID string) error
+
+	// Open returns the content of a blob, or ErrNotFound.
+	Open(key string) (io.ReadSeekCloser, error)
+}
+
+var Blobs Store
+
+// Init sets Blobs to local disk storage under ATTACHMENT_DIR, or
+// ./data/attachments when it is not set.
+func Init() {
+	root := os.Getenv("ATTACHMENT_DIR")
+	if root == "" {
+		root = "./data/attachments"
+	}
+
+	local, err := NewLocal(root)
+	if err != nil {
+		log.Fatal("Error preparing attachment storage: ", err)
+	}
+	Blobs = local
+
+	log.Printf("Attachment storage ready at %s", root)
+}
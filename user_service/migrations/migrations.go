@@ -6,6 +6,63 @@ import (
 	"user_service/internal/models"
 )
 
+const backfillConversationTypeQuery = `
+UPDATE conversations SET conversation_type = ?
+WHERE (conversation_type IS NULL OR conversation_type = '') AND profile1_id IS NOT NULL`
+
+const backfillMembersQuery = `
+INSERT INTO conversation_members (created_at, updated_at, conversation_id, user_id)
+SELECT now(), now(), conversations.id, sides.profile_id
+FROM conversations
+CROSS JOIN LATERAL (VALUES (conversations.profile1_id), (conversations.profile2_id)) AS sides(profile_id)
+WHERE conversations.deleted_at IS NULL AND sides.profile_id IS NOT NULL
+	AND NOT EXISTS (SELECT 1 FROM conversation_members
+		WHERE conversation_members.conversation_id = conversations.id
+		AND conversation_members.user_id = sides.profile_id
+		AND conversation_members.deleted_at IS NULL)`
+
+// backfillGroupOwnersQuery makes the earliest member the owner of every group
+// created before roles existed.
+const backfillGroupOwnersQuery = `
+UPDATE conversation_members SET role = ?
+WHERE id IN (
+	SELECT DISTINCT ON (conversation_members.conversation_id) conversation_members.id
+	FROM conversation_members
+	JOIN conversations ON conversations.id = conversation_members.conversation_id
+	WHERE conversations.conversation_type = ? AND conversation_members.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM conversation_members AS owners
+			WHERE owners.conversation_id = conversation_members.conversation_id
+			AND owners.role = ? AND owners.deleted_at IS NULL)
+	ORDER BY conversation_members.conversation_id, conversation_members.created_at, conversation_members.id)`
+
+// recordReceiptsFunction is the one place receipts are written; both the
+// chat_service receipt frame and MarkConversationRead call it. It upserts a
+// receipt for every message up to p_up_to that the profile did not send,
+// setting each timestamp only once. The legacy is_read flag is only kept for
+// one-to-one conversations: in a group, one member reading a message says
+// nothing about the others.
+const recordReceiptsFunction = `
+CREATE OR REPLACE FUNCTION record_receipts(p_conversation bigint, p_profile bigint, p_up_to bigint, p_read boolean, p_now timestamptz)
+RETURNS void LANGUAGE sql AS $$
+INSERT INTO message_receipts (created_at, updated_at, message_id, profile_id, delivered_at, read_at)
+SELECT p_now, p_now, messages.id, p_profile, p_now, CASE WHEN p_read THEN p_now END
+FROM messages
+WHERE messages.conversation_id = p_conversation AND messages.id <= p_up_to
+	AND messages.sender_id <> p_profile AND messages.deleted_at IS NULL
+ON CONFLICT (message_id, profile_id) DO UPDATE SET
+	updated_at = EXCLUDED.updated_at,
+	delivered_at = COALESCE(message_receipts.delivered_at, EXCLUDED.delivered_at),
+	read_at = COALESCE(message_receipts.read_at, EXCLUDED.read_at)
+WHERE message_receipts.delivered_at IS NULL OR (p_read AND message_receipts.read_at IS NULL);
+
+UPDATE messages SET is_read = true
+FROM conversations
+WHERE p_read AND conversations.id = messages.conversation_id
+	AND conversations.conversation_type = 'one_to_one'
+	AND messages.conversation_id = p_conversation AND messages.id <= p_up_to
+	AND messages.sender_id <> p_profile AND messages.is_read = false;
+$$`
+
 func init() {
 	database.LoadInitializers()
 	database.ConnectToDb()
@@ -15,22 +72,76 @@ func main() {
 	if err := database.DB.AutoMigrate(&models.Profile{}); err != nil {
 		log.Printf("Error migrating Profile: %v", err)
 	}
+	// Conversations reference their avatar attachment.
+	if err := database.DB.AutoMigrate(&models.Attachment{}); err != nil {
+		log.Printf("Error migrating Attachment: %v", err)
+	}
+	if err := database.DB.AutoMigrate(&models.AttachmentUpload{}); err != nil {
+		log.Printf("Error migrating AttachmentUpload: %v", err)
+	}
 
 	if err := database.DB.AutoMigrate(&models.Conversations{}); err != nil {
 		log.Printf("Error migrating Conversations: %v", err)
 	}
+	// Groups have no Profile1ID/Profile2ID; older schemas declared them
+	// not null.
+	if err := database.DB.Exec("ALTER TABLE conversations ALTER COLUMN profile1_id DROP NOT NULL, ALTER COLUMN profile2_id DROP NOT NULL").Error; err != nil {
+		log.Printf("Error relaxing conversation profile columns: %v", err)
+	}
+	if err := database.DB.AutoMigrate(&models.LinkPreview{}); err != nil {
+		log.Printf("Error migrating LinkPreview: %v", err)
+	}
+	// Client message ids used to be unique per sender across conversations.
+	if err := database.DB.Exec("DROP INDEX IF EXISTS idx_sender_client_msg").Error; err != nil {
+		log.Printf("Error dropping old client message index: %v", err)
+	}
 	if err := database.DB.AutoMigrate(&models.Messages{}); err != nil {
 		log.Printf("Error migrating Messages: %v", err)
 	}
+	// GetMessages pages through a conversation by id.
+	if err := database.DB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_conversation_id_id ON messages (conversation_id, id)").Error; err != nil {
+		log.Printf("Error creating messages pagination index: %v", err)
+	}
 	if err := database.DB.AutoMigrate(&models.MessageContent{}); err != nil {
 		log.Printf("Error migrating MessageContent: %v", err)
 	}
+	if err := database.DB.AutoMigrate(&models.MessageReceipt{}); err != nil {
+		log.Printf("Error migrating MessageReceipt: %v", err)
+	}
+	if err := database.DB.Exec(recordReceiptsFunction).Error; err != nil {
+		log.Printf("Error creating record_receipts: %v", err)
+	}
+	if err := database.DB.AutoMigrate(&models.MessageEdit{}); err != nil {
+		log.Printf("Error migrating MessageEdit: %v", err)
+	}
+	if err := database.DB.AutoMigrate(&models.MessageReaction{}); err != nil {
+		log.Printf("Error migrating MessageReaction: %v", err)
+	}
 
 	if err := database.DB.AutoMigrate(&models.ConversationMember{}); err != nil {
 		log.Printf("Error migrating ConversationMember: %v", err)
 	}
+	// Access is decided by membership, so one-to-one conversations created
+	// before groups existed get a member row for each side.
+	if err := database.DB.Exec(backfillConversationTypeQuery, models.OneToOne).Error; err != nil {
+		log.Printf("Error backfilling conversation types: %v", err)
+	}
+	if err := database.DB.Exec(backfillMembersQuery).Error; err != nil {
+		log.Printf("Error backfilling conversation members: %v", err)
+	}
+	if err := database.DB.Exec(backfillGroupOwnersQuery, models.RoleOwner, models.Group, models.RoleOwner).Error; err != nil {
+		log.Printf("Error backfilling group owners: %v", err)
+	}
 	
 	if err := database.DB.AutoMigrate(&models.FriendRequest{}); err != nil {
 		log.Printf("Error migrating FriendRequest: %v", err)
 	}
+
+	if err := database.DB.AutoMigrate(&models.BrokerPayload{}); err != nil {
+		log.Printf("Error migrating BrokerPayload: %v", err)
+	}
+
+	if err := database.DB.AutoMigrate(&models.PresenceConnection{}); err != nil {
+		log.Printf("Error migrating PresenceConnection: %v", err)
+	}
 }